@@ -0,0 +1,515 @@
+package clawde
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CassetteDirection identifies which side of the transport produced a line.
+type CassetteDirection string
+
+const (
+	// CassetteSend marks a line written by the SDK to the CLI.
+	CassetteSend CassetteDirection = "send"
+
+	// CassetteRecv marks a line read by the SDK from the CLI.
+	CassetteRecv CassetteDirection = "recv"
+)
+
+// CassetteEntry is a single recorded line.
+// A cassette file is a sequence of entries, one JSON object per line.
+type CassetteEntry struct {
+	Time time.Time         `json:"time"`
+	Dir  CassetteDirection `json:"dir"`
+	Line string            `json:"line"`
+}
+
+// LoadCassette reads cassette entries from r.
+func LoadCassette(r io.Reader) ([]CassetteEntry, error) {
+	var entries []CassetteEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry CassetteEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, &ParseError{Line: string(line), Err: err}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// LoadCassetteFile reads cassette entries from the file at path.
+func LoadCassetteFile(path string) ([]CassetteEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadCassette(f)
+}
+
+// RecordingTransport wraps a Transport and records every line in both
+// directions to a cassette.
+type RecordingTransport struct {
+	inner  Transport
+	w      io.Writer
+	msgCh  chan json.RawMessage
+	doneCh chan struct{}
+	wg     sync.WaitGroup // forwardLoop
+	mu     sync.Mutex
+	closed bool
+	err    error
+}
+
+// NewRecordingTransport creates a transport that records all traffic of inner to w.
+func NewRecordingTransport(inner Transport, w io.Writer) *RecordingTransport {
+	return &RecordingTransport{
+		inner:  inner,
+		w:      w,
+		msgCh:  make(chan json.RawMessage, 100),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start starts the underlying transport and begins recording.
+func (t *RecordingTransport) Start(ctx context.Context) error {
+	if err := t.inner.Start(ctx); err != nil {
+		return err
+	}
+	t.wg.Add(1)
+	go t.forwardLoop()
+	return nil
+}
+
+// forwardLoop records incoming lines and passes them on until the inner
+// transport's channel closes or Close is called.
+func (t *RecordingTransport) forwardLoop() {
+	defer t.wg.Done()
+	defer close(t.msgCh)
+
+	for {
+		select {
+		case raw, ok := <-t.inner.Messages():
+			if !ok {
+				return
+			}
+			t.record(CassetteRecv, raw)
+			select {
+			case t.msgCh <- raw:
+			case <-t.doneCh:
+				return
+			}
+		case <-t.doneCh:
+			return
+		}
+	}
+}
+
+// record appends an entry to the cassette.
+func (t *RecordingTransport) record(dir CassetteDirection, line []byte) {
+	entry := CassetteEntry{
+		Time: time.Now(),
+		Dir:  dir,
+		Line: strings.TrimRight(string(line), "\r\n"),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	if _, err := t.w.Write(append(data, '\n')); err != nil {
+		t.err = err
+	}
+}
+
+// Write records data and sends it to the underlying transport.
+func (t *RecordingTransport) Write(data []byte) error {
+	t.record(CassetteSend, data)
+	return t.inner.Write(data)
+}
+
+// Messages returns the channel of incoming messages.
+func (t *RecordingTransport) Messages() <-chan json.RawMessage {
+	return t.msgCh
+}
+
+// Errors returns the underlying transport's error channel.
+func (t *RecordingTransport) Errors() <-chan error {
+	return t.inner.Errors()
+}
+
+// Err returns the first error encountered writing the cassette.
+func (t *RecordingTransport) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Close shuts down the underlying transport. Once it returns, nothing more
+// is written to the cassette.
+func (t *RecordingTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.doneCh)
+	t.mu.Unlock()
+
+	err := t.inner.Close()
+	t.wg.Wait()
+	return err
+}
+
+// Divergence describes an outgoing line that did not match the cassette.
+type Divergence struct {
+	// Index is the position of the expected entry in the cassette (-1 if unexpected).
+	Index int
+
+	// Want is the recorded line (empty if the write was unexpected).
+	Want string
+
+	// Got is the line written during replay (empty if it never arrived).
+	Got string
+}
+
+func (d Divergence) String() string {
+	switch {
+	case d.Got == "":
+		return fmt.Sprintf("entry %d: missing write %s", d.Index, truncate(d.Want, 200))
+	case d.Want == "":
+		return fmt.Sprintf("unexpected write %s", truncate(d.Got, 200))
+	default:
+		return fmt.Sprintf("entry %d: want %s, got %s", d.Index, truncate(d.Want, 200), truncate(d.Got, 200))
+	}
+}
+
+// ReplayError reports divergences between a replay and its cassette.
+type ReplayError struct {
+	Divergences []Divergence
+}
+
+func (e *ReplayError) Error() string {
+	parts := make([]string, len(e.Divergences))
+	for i, d := range e.Divergences {
+		parts[i] = d.String()
+	}
+	return fmt.Sprintf("clawde: replay diverged from cassette: %s", strings.Join(parts, "; "))
+}
+
+// ReplayTransport implements Transport by replaying a recorded cassette.
+//
+// Recorded incoming lines are delivered in order. Before replaying past a
+// recorded outgoing line, the transport waits for the SDK to write the
+// corresponding line and compares the two. Request IDs generated by the SDK
+// are mapped so that recorded responses are routed to the live requests.
+type ReplayTransport struct {
+	// WriteTimeout bounds how long replay waits for an expected write.
+	WriteTimeout time.Duration
+
+	// Realtime replays incoming lines with their recorded delays.
+	Realtime bool
+
+	// Strict reports each divergence on the error channel as a *ReplayError.
+	Strict bool
+
+	entries     []CassetteEntry
+	msgCh       chan json.RawMessage
+	errCh       chan error
+	writeCh     chan string
+	doneCh      chan struct{}
+	replayedCh  chan struct{}
+	mu          sync.Mutex
+	started     bool
+	closed      bool
+	idMap       map[string]string
+	divergences []Divergence
+}
+
+// NewReplayTransport creates a transport that replays the given entries.
+func NewReplayTransport(entries []CassetteEntry) *ReplayTransport {
+	return &ReplayTransport{
+		WriteTimeout: 5 * time.Second,
+		entries:      entries,
+		msgCh:        make(chan json.RawMessage, 100),
+		errCh:        make(chan error, 10),
+		writeCh:      make(chan string, 100),
+		doneCh:       make(chan struct{}),
+		replayedCh:   make(chan struct{}),
+		idMap:        make(map[string]string),
+	}
+}
+
+// Start begins replaying the cassette.
+func (t *ReplayTransport) Start(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrStreamClosed
+	}
+	if t.started {
+		return nil
+	}
+	t.started = true
+
+	go t.replayLoop(ctx)
+	return nil
+}
+
+// replayLoop walks the cassette, emitting incoming lines and checking outgoing ones.
+func (t *ReplayTransport) replayLoop(ctx context.Context) {
+	defer close(t.msgCh)
+
+	var last time.Time
+	for i := 0; i < len(t.entries); {
+		entry := t.entries[i]
+		if entry.Dir == CassetteSend {
+			// Consecutive outgoing lines may be written in any order,
+			// since control requests are answered concurrently.
+			j := i
+			for j < len(t.entries) && t.entries[j].Dir == CassetteSend {
+				j++
+			}
+			if !t.expectWrites(ctx, i, j) {
+				return
+			}
+			i = j
+			continue
+		}
+
+		if t.Realtime && !last.IsZero() {
+			select {
+			case <-time.After(entry.Time.Sub(last)):
+			case <-t.doneCh:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+		last = entry.Time
+
+		select {
+		case t.msgCh <- t.rewriteIncoming(entry.Line):
+		case <-t.doneCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+		i++
+	}
+
+	close(t.replayedCh)
+
+	// Keep the stream open like a live CLI until the SDK hangs up.
+	select {
+	case <-t.doneCh:
+	case <-ctx.Done():
+	}
+}
+
+// expectWrites waits for the SDK to write the recorded lines entries[from:to]
+// in any order. It returns false if the replay was stopped.
+func (t *ReplayTransport) expectWrites(ctx context.Context, from, to int) bool {
+	var pending []int
+	for i := from; i < to; i++ {
+		pending = append(pending, i)
+	}
+
+	for len(pending) > 0 {
+		select {
+		case got := <-t.writeCh:
+			matched := -1
+			for n, i := range pending {
+				if t.matchWrite(t.entries[i].Line, got) {
+					matched = n
+					break
+				}
+			}
+			if matched < 0 {
+				// Report the mismatch against the oldest expected line.
+				matched = 0
+				t.diverge(Divergence{Index: pending[0], Want: t.entries[pending[0]].Line, Got: got})
+			}
+			pending = append(pending[:matched], pending[matched+1:]...)
+
+		case <-time.After(t.WriteTimeout):
+			for _, i := range pending {
+				t.diverge(Divergence{Index: i, Want: t.entries[i].Line})
+			}
+			return true
+
+		case <-t.doneCh:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// matchWrite reports whether a live write matches a recorded one, mapping
+// the request IDs of SDK-initiated control requests when it does.
+func (t *ReplayTransport) matchWrite(want, got string) bool {
+	var wantMsg, gotMsg map[string]any
+	if json.Unmarshal([]byte(want), &wantMsg) != nil || json.Unmarshal([]byte(got), &gotMsg) != nil {
+		return want == got
+	}
+
+	// Session IDs of prompts are generated per run unless set explicitly.
+	if wantMsg["type"] == "user" && gotMsg["type"] == "user" {
+		delete(wantMsg, "session_id")
+		delete(gotMsg, "session_id")
+	}
+
+	// Request IDs of SDK-initiated control requests are generated per run.
+	var wantID, gotID string
+	if wantMsg["type"] == "control_request" && gotMsg["type"] == "control_request" {
+		wantID, _ = wantMsg["request_id"].(string)
+		gotID, _ = gotMsg["request_id"].(string)
+		delete(wantMsg, "request_id")
+		delete(gotMsg, "request_id")
+	}
+
+	if !reflect.DeepEqual(wantMsg, gotMsg) {
+		return false
+	}
+	if wantID != "" {
+		t.mu.Lock()
+		t.idMap[wantID] = gotID
+		t.mu.Unlock()
+	}
+	return true
+}
+
+// rewriteIncoming maps recorded request IDs in control responses to live ones.
+func (t *ReplayTransport) rewriteIncoming(line string) json.RawMessage {
+	var msg struct {
+		Type     string         `json:"type"`
+		Response map[string]any `json:"response"`
+	}
+	if json.Unmarshal([]byte(line), &msg) != nil || msg.Type != "control_response" || msg.Response == nil {
+		return json.RawMessage(line)
+	}
+
+	recorded, _ := msg.Response["request_id"].(string)
+	t.mu.Lock()
+	live, ok := t.idMap[recorded]
+	t.mu.Unlock()
+	if !ok {
+		return json.RawMessage(line)
+	}
+
+	msg.Response["request_id"] = live
+	data, err := json.Marshal(map[string]any{"type": msg.Type, "response": msg.Response})
+	if err != nil {
+		return json.RawMessage(line)
+	}
+	return data
+}
+
+// diverge records a divergence.
+func (t *ReplayTransport) diverge(d Divergence) {
+	t.mu.Lock()
+	t.divergences = append(t.divergences, d)
+	t.mu.Unlock()
+
+	if t.Strict {
+		select {
+		case t.errCh <- &ReplayError{Divergences: []Divergence{d}}:
+		default:
+		}
+	}
+}
+
+// Write receives a line from the SDK for comparison with the cassette.
+func (t *ReplayTransport) Write(data []byte) error {
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+	if closed {
+		return ErrStreamClosed
+	}
+
+	line := strings.TrimRight(string(data), "\r\n")
+	select {
+	case t.writeCh <- line:
+	default:
+		t.diverge(Divergence{Index: -1, Got: line})
+	}
+	return nil
+}
+
+// Messages returns the channel of replayed messages.
+func (t *ReplayTransport) Messages() <-chan json.RawMessage {
+	return t.msgCh
+}
+
+// Errors returns the error channel.
+func (t *ReplayTransport) Errors() <-chan error {
+	return t.errCh
+}
+
+// Close stops the replay.
+func (t *ReplayTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.doneCh)
+	return nil
+}
+
+// Divergences returns the divergences found so far.
+func (t *ReplayTransport) Divergences() []Divergence {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Divergence(nil), t.divergences...)
+}
+
+// Verify waits for the cassette to be fully replayed and returns a
+// *ReplayError if any outgoing line diverged or was never written.
+// Writes left over after the cassette ended are reported as unexpected.
+func (t *ReplayTransport) Verify(ctx context.Context) error {
+	select {
+	case <-t.replayedCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for drained := false; !drained; {
+		select {
+		case got := <-t.writeCh:
+			t.diverge(Divergence{Index: -1, Got: got})
+		default:
+			drained = true
+		}
+	}
+
+	if divergences := t.Divergences(); len(divergences) > 0 {
+		return &ReplayError{Divergences: divergences}
+	}
+	return nil
+}
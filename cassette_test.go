@@ -0,0 +1,204 @@
+package clawde_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+// runPermissionTurn runs one query whose tool request is answered by decide.
+func runPermissionTurn(t *testing.T, ctx context.Context, decide clawde.PermissionResult, opts ...clawde.Option) string {
+	t.Helper()
+
+	opts = append(opts, clawde.WithPermissionCallback(func(ctx context.Context, req *clawde.PermissionRequest) clawde.PermissionResult {
+		return decide
+	}))
+	client, err := clawde.NewClient(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+
+	stream, err := client.Query(ctx, "list files")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	text, err := stream.CollectText()
+	if err != nil {
+		t.Fatalf("CollectText: %v", err)
+	}
+	return text
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.CanUseTool("Bash", map[string]any{"command": "ls"}),
+		clawdetest.AssistantText("listed"),
+		clawdetest.Result("listed"),
+	)
+
+	var cassette bytes.Buffer
+	runPermissionTurn(t, ctx, clawde.Allow(), clawde.WithTransport(ft), clawde.WithRecorder(&cassette))
+
+	entries, err := clawde.LoadCassette(&cassette)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("cassette is empty")
+	}
+
+	replay := clawde.NewReplayTransport(entries)
+	if text := runPermissionTurn(t, ctx, clawde.Allow(), clawde.WithTransport(replay)); text != "listed" {
+		t.Errorf("replayed text = %q, want %q", text, "listed")
+	}
+	if err := replay.Verify(ctx); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	// A different permission decision must be reported as a divergence.
+	replay = clawde.NewReplayTransport(entries)
+	runPermissionTurn(t, ctx, clawde.Deny("nope"), clawde.WithTransport(replay))
+	var replayErr *clawde.ReplayError
+	if err := replay.Verify(ctx); !errors.As(err, &replayErr) {
+		t.Fatalf("Verify = %v, want *ReplayError", err)
+	}
+	if len(replayErr.Divergences) != 1 {
+		t.Errorf("got %d divergences, want 1: %v", len(replayErr.Divergences), replayErr)
+	}
+}
+
+func TestReplayWithSeveralHookEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// run performs one query and returns the hooks that fired, in order.
+	run := func(transport clawde.Transport, recorder *bytes.Buffer) []string {
+		t.Helper()
+
+		var fired []string
+		record := func(name string) clawde.HookCallback {
+			return func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+				fired = append(fired, name+":"+in.ToolName)
+				return clawde.ContinueHook(), nil
+			}
+		}
+		opts := []clawde.Option{
+			clawde.WithTransport(transport),
+			clawde.WithHook(clawde.HookPreToolUse, clawde.MatchTool("Bash", record("pre"))),
+			clawde.WithHook(clawde.HookPostToolUse, clawde.MatchTool("Bash", record("post"))),
+			clawde.WithHook(clawde.HookStop, clawde.MatchAll(record("stop"))),
+		}
+		if recorder != nil {
+			opts = append(opts, clawde.WithRecorder(recorder))
+		}
+		client, err := clawde.NewClient(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Connect(ctx); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		defer client.Close()
+
+		stream, err := client.Query(ctx, "run ls")
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		if err := stream.Wait(); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+		return fired
+	}
+
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "Bash"}),
+		clawdetest.Hook("PostToolUse", map[string]any{"tool_name": "Bash"}),
+		clawdetest.Hook("Stop", nil),
+		clawdetest.Result("done"),
+	)
+	var cassette bytes.Buffer
+	want := []string{"pre:Bash", "post:Bash", "stop:"}
+	if got := run(ft, &cassette); !slices.Equal(got, want) {
+		t.Fatalf("recorded hooks = %v, want %v", got, want)
+	}
+	entries, err := clawde.LoadCassette(&cassette)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+
+	// Map iteration order changes between runs; replay several times so a
+	// nondeterministic initialize request would show up.
+	for i := 0; i < 10; i++ {
+		replay := clawde.NewReplayTransport(entries)
+		if got := run(replay, nil); !slices.Equal(got, want) {
+			t.Fatalf("replay %d: hooks = %v, want %v", i, got, want)
+		}
+		if err := replay.Verify(ctx); err != nil {
+			t.Fatalf("replay %d: Verify: %v", i, err)
+		}
+	}
+}
+
+// slowWriter delays each write, keeping a recorder busy.
+type slowWriter struct{ w io.Writer }
+
+func (s slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return s.w.Write(p)
+}
+
+func TestRecorderClosedWithClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	steps := []clawdetest.Step{clawdetest.WaitForPrompt()}
+	for i := 0; i < 50; i++ {
+		steps = append(steps, clawdetest.AssistantText("chatter"))
+	}
+	var cassette bytes.Buffer
+	client, err := clawde.NewClient(clawde.WithTransport(clawdetest.NewTransport(steps...)), clawde.WithRecorder(slowWriter{&cassette}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	stream, err := client.Query(ctx, "chat")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !stream.Next() {
+		t.Fatalf("stream ended early: %v", stream.Err())
+	}
+
+	// Close waits for the recorder, so the cassette can be read right away.
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	entries, err := clawde.LoadCassette(&cassette)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if i := slices.IndexFunc(entries, func(e clawde.CassetteEntry) bool {
+		return e.Dir == clawde.CassetteRecv && strings.Contains(e.Line, "chatter")
+	}); i < 0 {
+		t.Errorf("cassette has no received message: %+v", entries)
+	}
+}
@@ -0,0 +1,354 @@
+package clawdetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// errExit stops the script and closes the message channel.
+var errExit = errors.New("clawdetest: exit")
+
+// Step is a single action in a fake CLI script.
+type Step interface {
+	run(ctx context.Context, t *Transport) error
+}
+
+// StepFunc adapts a function to a Step.
+type StepFunc func(ctx context.Context, t *Transport) error
+
+func (f StepFunc) run(ctx context.Context, t *Transport) error {
+	return f(ctx, t)
+}
+
+// Emit sends v to the SDK as a raw stream-json line.
+func Emit(v any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		if raw, ok := v.(json.RawMessage); ok {
+			return t.deliver(raw)
+		}
+		return t.send(v)
+	})
+}
+
+// WaitForPrompt blocks until the SDK writes a user message.
+func WaitForPrompt() Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		_, err := t.waitPrompt(ctx)
+		return err
+	})
+}
+
+// Sleep pauses the script.
+func Sleep(d time.Duration) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-t.doneCh:
+			return ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Exit ends the script and closes the message channel, like the CLI exiting.
+func Exit() Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		return errExit
+	})
+}
+
+// Fail sends err on the error channel.
+func Fail(err error) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		select {
+		case t.errCh <- err:
+			return nil
+		case <-t.doneCh:
+			return ErrClosed
+		}
+	})
+}
+
+// System emits a system message with the given subtype.
+func System(subtype string) Step {
+	return SystemWith(subtype, nil)
+}
+
+// SystemWith emits a system message with the given subtype and fields.
+// session_id is filled in when missing.
+func SystemWith(subtype string, fields map[string]any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		msg := map[string]any{
+			"type":       "system",
+			"subtype":    subtype,
+			"session_id": t.SessionID,
+		}
+		for k, v := range fields {
+			msg[k] = v
+		}
+		return t.send(msg)
+	})
+}
+
+// Assistant emits an assistant message with the given content blocks.
+// Blocks are marshaled as-is, e.g. map[string]any{"type": "text", "text": "hi"}.
+func Assistant(blocks ...any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		return t.send(map[string]any{
+			"type": "assistant",
+			"message": map[string]any{
+				"role":    "assistant",
+				"model":   t.Model,
+				"content": blocks,
+			},
+			"parent_tool_use_id": nil,
+			"session_id":         t.SessionID,
+		})
+	})
+}
+
+// AssistantText emits an assistant message containing a single text block.
+func AssistantText(text string) Step {
+	return Assistant(map[string]any{"type": "text", "text": text})
+}
+
+// ToolUse emits an assistant message containing a single tool_use block.
+func ToolUse(id, name string, input any) Step {
+	return Assistant(map[string]any{
+		"type":  "tool_use",
+		"id":    id,
+		"name":  name,
+		"input": input,
+	})
+}
+
+// ToolResult emits a user message carrying a tool result.
+func ToolResult(toolUseID, content string, isError bool) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		return t.send(map[string]any{
+			"type": "user",
+			"message": map[string]any{
+				"role": "user",
+				"content": []any{map[string]any{
+					"type":        "tool_result",
+					"tool_use_id": toolUseID,
+					"content":     content,
+					"is_error":    isError,
+				}},
+			},
+			"parent_tool_use_id": nil,
+			"session_id":         t.SessionID,
+		})
+	})
+}
+
+// Result emits a successful result message ending the turn.
+func Result(text string) Step {
+	return ResultWith(map[string]any{
+		"subtype":  "success",
+		"is_error": false,
+		"result":   text,
+	})
+}
+
+// ResultWith emits a result message with the given fields.
+// type, session_id and num_turns are filled in when missing.
+func ResultWith(fields map[string]any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		msg := map[string]any{
+			"type":       "result",
+			"subtype":    "success",
+			"session_id": t.SessionID,
+			"num_turns":  1,
+		}
+		for k, v := range fields {
+			msg[k] = v
+		}
+		return t.send(msg)
+	})
+}
+
+// Reply waits for a prompt and answers it with a text message and a result.
+func Reply(text string) Step {
+	return Sequence(WaitForPrompt(), AssistantText(text), Result(text))
+}
+
+// Sequence runs steps one after another as a single step.
+func Sequence(steps ...Step) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		for _, s := range steps {
+			if err := s.run(ctx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Concurrently runs steps at the same time and waits for all of them,
+// like the CLI issuing several control requests without waiting.
+// The first error is returned.
+func Concurrently(steps ...Step) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		errs := make(chan error, len(steps))
+		for _, s := range steps {
+			go func(s Step) { errs <- s.run(ctx, t) }(s)
+		}
+		var first error
+		for range steps {
+			if err := <-errs; err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+}
+
+// CanUseTool asks the SDK for permission to use a tool and records the answer.
+func CanUseTool(toolName string, input any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		_, err := t.request(ctx, "can_use_tool", map[string]any{
+			"tool_name": toolName,
+			"input":     input,
+		})
+		return err
+	})
+}
+
+// CancelPending cancels every scripted control request still awaiting the
+// SDK's answer, like the CLI sending control_cancel_request when a tool call
+// is aborted. Use it with Concurrently to cancel a request mid-flight.
+func CancelPending() Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		return t.cancelPending()
+	})
+}
+
+// HookCallback invokes a single hook callback by ID and records the answer.
+func HookCallback(callbackID string, input map[string]any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		_, err := t.request(ctx, "hook_callback", map[string]any{
+			"callback_id": callbackID,
+			"input":       input,
+			"tool_use_id": input["tool_use_id"],
+		})
+		return err
+	})
+}
+
+// Hook fires an event the way the CLI does: every callback registered in the
+// initialize request whose matcher accepts input["tool_name"] is invoked.
+// hook_event_name and session_id are filled in when missing.
+func Hook(event string, input map[string]any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		full := map[string]any{
+			"hook_event_name": event,
+			"session_id":      t.SessionID,
+		}
+		for k, v := range input {
+			full[k] = v
+		}
+		toolName, _ := full["tool_name"].(string)
+
+		ids, err := t.hookCallbackIDs(event, toolName)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := HookCallback(id, full).run(ctx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// hookCallbackIDs returns the callback IDs registered for event whose matcher
+// accepts toolName, in registration order.
+func (t *Transport) hookCallbackIDs(event, toolName string) ([]string, error) {
+	var init struct {
+		Hooks map[string][]struct {
+			Matcher         string   `json:"matcher"`
+			HookCallbackIDs []string `json:"hookCallbackIds"`
+		} `json:"hooks"`
+	}
+	if raw := t.InitializeRequest(); raw != nil {
+		if err := json.Unmarshal(raw, &init); err != nil {
+			return nil, fmt.Errorf("decode initialize request: %w", err)
+		}
+	}
+
+	var ids []string
+	for _, m := range init.Hooks[event] {
+		ok, err := t.matchTool(m.Matcher, toolName)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids = append(ids, m.HookCallbackIDs...)
+		}
+	}
+	return ids, nil
+}
+
+// simpleMatcher matches matchers made only of tool names and alternation.
+var simpleMatcher = regexp.MustCompile(`^[A-Za-z0-9_|]+$`)
+
+// matchTool reports whether a CLI hook matcher accepts toolName, using the
+// Claude Code settings syntax: "*" or "" match everything, plain names and
+// alternations match exactly, anything else is a regular expression.
+// Regular expressions are compiled once per transport.
+func (t *Transport) matchTool(pattern, toolName string) (bool, error) {
+	if pattern == "" || pattern == "*" || toolName == "" {
+		return true, nil
+	}
+	if simpleMatcher.MatchString(pattern) {
+		for _, name := range strings.Split(pattern, "|") {
+			if name == toolName {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	t.mu.Lock()
+	re, ok := t.matchers[pattern]
+	t.mu.Unlock()
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, fmt.Errorf("invalid matcher %q: %w", pattern, err)
+		}
+		t.mu.Lock()
+		t.matchers[pattern] = re
+		t.mu.Unlock()
+	}
+	return re.MatchString(toolName), nil
+}
+
+// MCPMessage routes an MCP request to an SDK server and records the answer.
+func MCPMessage(serverName, method string, params any) Step {
+	return StepFunc(func(ctx context.Context, t *Transport) error {
+		_, err := t.request(ctx, "mcp_message", map[string]any{
+			"server_name": serverName,
+			"method":      method,
+			"params":      params,
+		})
+		return err
+	})
+}
+
+// CallTool invokes a tool on an SDK MCP server via tools/call.
+func CallTool(serverName, toolName string, arguments any) Step {
+	return MCPMessage(serverName, "tools/call", map[string]any{
+		"name":      toolName,
+		"arguments": arguments,
+	})
+}
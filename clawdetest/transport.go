@@ -0,0 +1,497 @@
+// Package clawdetest provides an in-memory fake of the Claude CLI for testing
+// code built on the clawde SDK without a real claude binary or network access.
+//
+// A Transport plays the CLI side of the stream-json control protocol. It
+// answers the SDK's initialize request and then runs a script of steps that
+// emit messages and issue control requests back to the SDK:
+//
+//	ft := clawdetest.NewTransport(
+//		clawdetest.WaitForPrompt(),
+//		clawdetest.CanUseTool("Bash", map[string]any{"command": "ls"}),
+//		clawdetest.AssistantText("done"),
+//		clawdetest.Result("done"),
+//	)
+//	client, _ := clawde.NewClient(clawde.WithTransport(ft), clawde.WithPermissionCallback(cb))
+package clawdetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+)
+
+// DefaultSessionID is the session ID used by scripted messages.
+const DefaultSessionID = "test-session"
+
+// ErrClosed is returned when writing to a closed Transport.
+var ErrClosed = errors.New("clawdetest: transport closed")
+
+// ControlResponse records the SDK's answer to a control request issued by the script.
+type ControlResponse struct {
+	// RequestID is the ID of the control request.
+	RequestID string
+
+	// Subtype is the subtype of the request ("can_use_tool", "hook_callback", ...).
+	Subtype string
+
+	// Response is the raw response payload sent by the SDK.
+	Response json.RawMessage
+
+	// Error is the error message if the SDK answered with an error.
+	Error string
+}
+
+// Decode unmarshals the response payload into v.
+func (r *ControlResponse) Decode(v any) error {
+	return json.Unmarshal(r.Response, v)
+}
+
+// ControlHandler answers a control request sent by the SDK.
+type ControlHandler func(request json.RawMessage) (any, error)
+
+// Transport is an in-memory clawde.Transport driven by a script of steps.
+type Transport struct {
+	// SessionID is the session ID reported in scripted messages.
+	SessionID string
+
+	// Model is the model reported in scripted assistant messages.
+	Model string
+
+	// Timeout bounds how long a step waits for the SDK.
+	Timeout time.Duration
+
+	script   []Step
+	msgCh    chan json.RawMessage
+	errCh    chan error
+	doneCh   chan struct{}
+	exitCh   chan struct{}
+	initCh   chan struct{}
+	promptCh chan json.RawMessage
+
+	// sendMu is held for reading while a line is delivered on msgCh and
+	// for writing while run closes it.
+	sendMu sync.RWMutex
+
+	mu        sync.Mutex
+	started   bool
+	closed    bool
+	nextID    int
+	init      json.RawMessage
+	written   []json.RawMessage
+	prompts   []json.RawMessage
+	responses []*ControlResponse
+	stray     []*ControlResponse
+	pending   map[string]*pendingRequest
+	handlers  map[string]ControlHandler
+	matchers  map[string]*regexp.Regexp
+	requests  []json.RawMessage
+	scriptErr error
+}
+
+var _ clawde.Transport = (*Transport)(nil)
+
+// pendingRequest is a scripted control request awaiting the SDK's answer.
+type pendingRequest struct {
+	ch        chan *ControlResponse
+	cancelled chan struct{}
+}
+
+// NewTransport creates a fake transport that runs the given script once the
+// SDK has sent its initialize request.
+func NewTransport(script ...Step) *Transport {
+	return &Transport{
+		SessionID: DefaultSessionID,
+		Model:     "claude-test",
+		Timeout:   5 * time.Second,
+		script:    script,
+		msgCh:     make(chan json.RawMessage, 100),
+		errCh:     make(chan error, 10),
+		doneCh:    make(chan struct{}),
+		exitCh:    make(chan struct{}),
+		initCh:    make(chan struct{}),
+		promptCh:  make(chan json.RawMessage, 100),
+		pending:   make(map[string]*pendingRequest),
+		handlers:  make(map[string]ControlHandler),
+		matchers:  make(map[string]*regexp.Regexp),
+	}
+}
+
+// HandleControl registers a handler for SDK control requests with the given
+// subtype. Requests without a handler are answered with an empty success.
+func (t *Transport) HandleControl(subtype string, h ControlHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[subtype] = h
+}
+
+// Start begins running the script.
+func (t *Transport) Start(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrClosed
+	}
+	if t.started {
+		return nil
+	}
+	t.started = true
+
+	go t.run(ctx)
+	return nil
+}
+
+// run waits for initialization and executes the script steps in order.
+func (t *Transport) run(ctx context.Context) {
+	defer func() {
+		close(t.exitCh)
+		t.sendMu.Lock()
+		close(t.msgCh)
+		t.sendMu.Unlock()
+	}()
+
+	select {
+	case <-t.initCh:
+	case <-t.doneCh:
+		return
+	case <-ctx.Done():
+		return
+	}
+
+	for i, step := range t.script {
+		if err := step.run(ctx, t); err != nil {
+			if errors.Is(err, errExit) {
+				return
+			}
+			t.fail(fmt.Errorf("clawdetest: step %d: %w", i, err))
+			return
+		}
+	}
+
+	// Like the CLI, stay alive until the SDK hangs up.
+	select {
+	case <-t.doneCh:
+	case <-ctx.Done():
+	}
+}
+
+// Write receives a line written by the SDK.
+func (t *Transport) Write(data []byte) error {
+	line := make(json.RawMessage, len(data))
+	copy(line, data)
+
+	var envelope struct {
+		Type      string `json:"type"`
+		RequestID string `json:"request_id"`
+		Request   struct {
+			Subtype string `json:"subtype"`
+		} `json:"request"`
+		Response struct {
+			Subtype   string          `json:"subtype"`
+			RequestID string          `json:"request_id"`
+			Response  json.RawMessage `json:"response"`
+			Error     string          `json:"error"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return t.fail(fmt.Errorf("clawdetest: invalid JSON from SDK: %w", err))
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrClosed
+	}
+	t.written = append(t.written, line)
+	t.mu.Unlock()
+
+	switch envelope.Type {
+	case "control_request":
+		return t.answerControl(envelope.RequestID, envelope.Request.Subtype, line)
+
+	case "control_response":
+		resp := &ControlResponse{
+			RequestID: envelope.Response.RequestID,
+			Response:  envelope.Response.Response,
+			Error:     envelope.Response.Error,
+		}
+		t.mu.Lock()
+		p, ok := t.pending[envelope.Response.RequestID]
+		delete(t.pending, envelope.Response.RequestID)
+		if !ok {
+			t.stray = append(t.stray, resp)
+		}
+		t.mu.Unlock()
+		if ok {
+			p.ch <- resp
+		}
+
+	case "user":
+		t.mu.Lock()
+		t.prompts = append(t.prompts, line)
+		t.mu.Unlock()
+		select {
+		case t.promptCh <- line:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// answerControl replies to a control request sent by the SDK.
+func (t *Transport) answerControl(requestID, subtype string, line json.RawMessage) error {
+	var req struct {
+		Request json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		return t.fail(fmt.Errorf("clawdetest: invalid %s request from SDK: %w", subtype, err))
+	}
+
+	t.mu.Lock()
+	h := t.handlers[subtype]
+	first := false
+	if subtype == "initialize" && t.init == nil {
+		t.init = req.Request
+		first = true
+	}
+	t.requests = append(t.requests, req.Request)
+	t.mu.Unlock()
+
+	var (
+		payload any = map[string]any{}
+		err     error
+	)
+	if h != nil {
+		payload, err = h(req.Request)
+	}
+
+	inner := map[string]any{
+		"subtype":    "success",
+		"request_id": requestID,
+		"response":   payload,
+	}
+	if err != nil {
+		inner = map[string]any{
+			"subtype":    "error",
+			"request_id": requestID,
+			"error":      err.Error(),
+		}
+	}
+	if sendErr := t.send(map[string]any{"type": "control_response", "response": inner}); sendErr != nil {
+		return sendErr
+	}
+
+	if first {
+		close(t.initCh)
+	}
+	return nil
+}
+
+// send marshals v and delivers it to the SDK.
+func (t *Transport) send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.deliver(data)
+}
+
+// deliver hands a line to the SDK. It returns ErrClosed once the transport
+// is closed or the script has ended, so answers to late SDK writes are never
+// sent on the closed message channel.
+func (t *Transport) deliver(line json.RawMessage) error {
+	t.sendMu.RLock()
+	defer t.sendMu.RUnlock()
+
+	select {
+	case <-t.exitCh:
+		return ErrClosed
+	default:
+	}
+	select {
+	case t.msgCh <- line:
+		return nil
+	case <-t.exitCh:
+		return ErrClosed
+	case <-t.doneCh:
+		return ErrClosed
+	}
+}
+
+// fail records err as the transport's error and reports it to the SDK. The
+// first error wins.
+func (t *Transport) fail(err error) error {
+	t.mu.Lock()
+	if t.scriptErr == nil {
+		t.scriptErr = err
+	}
+	t.mu.Unlock()
+	select {
+	case t.errCh <- err:
+	default:
+	}
+	return err
+}
+
+// request issues a control request to the SDK and waits for its response.
+func (t *Transport) request(ctx context.Context, subtype string, fields map[string]any) (*ControlResponse, error) {
+	t.mu.Lock()
+	t.nextID++
+	requestID := fmt.Sprintf("req_%d", t.nextID)
+	p := &pendingRequest{
+		ch:        make(chan *ControlResponse, 1),
+		cancelled: make(chan struct{}),
+	}
+	t.pending[requestID] = p
+	t.mu.Unlock()
+
+	inner := map[string]any{"subtype": subtype}
+	for k, v := range fields {
+		inner[k] = v
+	}
+	if err := t.send(map[string]any{
+		"type":       "control_request",
+		"request_id": requestID,
+		"request":    inner,
+	}); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(t.Timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-p.ch:
+		resp.Subtype = subtype
+		t.mu.Lock()
+		t.responses = append(t.responses, resp)
+		t.mu.Unlock()
+		return resp, nil
+	case <-p.cancelled:
+		return nil, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no response to %s request %s after %v", subtype, requestID, t.Timeout)
+	case <-t.doneCh:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// cancelPending sends a control_cancel_request for every scripted request
+// still awaiting an answer. The cancelled requests return without a response.
+func (t *Transport) cancelPending() error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[string]*pendingRequest)
+	t.mu.Unlock()
+
+	for requestID, p := range pending {
+		close(p.cancelled)
+		if err := t.send(map[string]any{
+			"type":       "control_cancel_request",
+			"request_id": requestID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitPrompt blocks until the SDK writes a user message.
+func (t *Transport) waitPrompt(ctx context.Context) (json.RawMessage, error) {
+	timer := time.NewTimer(t.Timeout)
+	defer timer.Stop()
+
+	select {
+	case p := <-t.promptCh:
+		return p, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no prompt after %v", t.Timeout)
+	case <-t.doneCh:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Messages returns the channel of lines emitted by the fake CLI.
+func (t *Transport) Messages() <-chan json.RawMessage {
+	return t.msgCh
+}
+
+// Errors returns the channel of script errors.
+func (t *Transport) Errors() <-chan error {
+	return t.errCh
+}
+
+// Close shuts down the transport.
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.doneCh)
+	return nil
+}
+
+// Err returns the error that stopped the script or failed the transport, if
+// any.
+func (t *Transport) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scriptErr
+}
+
+// InitializeRequest returns the initialize request sent by the SDK.
+func (t *Transport) InitializeRequest() json.RawMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.init
+}
+
+// ControlRequests returns every control request sent by the SDK, including initialize.
+func (t *Transport) ControlRequests() []json.RawMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]json.RawMessage(nil), t.requests...)
+}
+
+// Prompts returns every user message written by the SDK.
+func (t *Transport) Prompts() []json.RawMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]json.RawMessage(nil), t.prompts...)
+}
+
+// Written returns every line written by the SDK.
+func (t *Transport) Written() []json.RawMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]json.RawMessage(nil), t.written...)
+}
+
+// Responses returns the SDK's answers to scripted control requests, in order.
+func (t *Transport) Responses() []*ControlResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*ControlResponse(nil), t.responses...)
+}
+
+// StrayResponses returns control responses written by the SDK for requests
+// that were cancelled or never issued.
+func (t *Transport) StrayResponses() []*ControlResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*ControlResponse(nil), t.stray...)
+}
@@ -0,0 +1,156 @@
+package clawdetest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestTransportControlProtocol(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type echoInput struct {
+		Text string `json:"text"`
+	}
+	server := clawde.NewMCPServer("util")
+	server.Tools = append(server.Tools, clawde.Tool("echo", "Echo text", func(ctx context.Context, in echoInput) (string, error) {
+		return "echo: " + in.Text, nil
+	}))
+
+	var hookTool string
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.CanUseTool("Bash", map[string]any{"command": "rm -rf /"}),
+		clawdetest.Hook("PreToolUse", map[string]any{
+			"tool_name":   "Read",
+			"tool_use_id": "tu_1",
+			"tool_input":  map[string]any{"file_path": "/tmp/x"},
+		}),
+		clawdetest.CallTool("util", "echo", map[string]any{"text": "hi"}),
+		clawdetest.AssistantText("all done"),
+		clawdetest.Result("all done"),
+	)
+
+	client, err := clawde.NewClient(
+		clawde.WithTransport(ft),
+		clawde.WithSDKServer("util", server),
+		clawde.WithPermissionCallback(func(ctx context.Context, req *clawde.PermissionRequest) clawde.PermissionResult {
+			if req.ToolName == "Bash" {
+				return clawde.Deny("no shell")
+			}
+			return clawde.Allow()
+		}),
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchTool("Read", func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+			hookTool = in.ToolName
+			return clawde.ContinueHook(), nil
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+
+	stream, err := client.Query(ctx, "hello")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	text, err := stream.CollectText()
+	if err != nil {
+		t.Fatalf("CollectText: %v", err)
+	}
+	if text != "all done" {
+		t.Errorf("text = %q, want %q", text, "all done")
+	}
+	if err := ft.Err(); err != nil {
+		t.Fatalf("script: %v", err)
+	}
+
+	responses := ft.Responses()
+	if len(responses) != 3 {
+		t.Fatalf("got %d control responses, want 3", len(responses))
+	}
+
+	var perm struct {
+		Allowed bool   `json:"allowed"`
+		Reason  string `json:"reason"`
+	}
+	if err := responses[0].Decode(&perm); err != nil {
+		t.Fatal(err)
+	}
+	if perm.Allowed || perm.Reason != "no shell" {
+		t.Errorf("permission response = %+v, want denied with reason", perm)
+	}
+
+	if hookTool != "Read" {
+		t.Errorf("hook saw tool %q, want Read", hookTool)
+	}
+
+	var mcp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := responses[2].Decode(&mcp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(mcp.Result), "echo: hi") {
+		t.Errorf("mcp result = %s, want echo", mcp.Result)
+	}
+
+	if n := len(ft.Prompts()); n != 1 {
+		t.Errorf("got %d prompts, want 1", n)
+	}
+}
+
+func TestWriteAfterExit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ft := clawdetest.NewTransport(clawdetest.Exit())
+	if err := ft.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.Write([]byte(`{"type":"control_request","request_id":"req_1","request":{"subtype":"initialize"}}`)); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	for range ft.Messages() {
+	}
+
+	// The CLI has exited: the request must fail instead of being answered
+	// on the closed message channel.
+	err := ft.Write([]byte(`{"type":"control_request","request_id":"req_2","request":{"subtype":"interrupt"}}`))
+	if !errors.Is(err, clawdetest.ErrClosed) {
+		t.Errorf("Write after Exit = %v, want ErrClosed", err)
+	}
+}
+
+func TestMalformedWriteFailsTransport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ft := clawdetest.NewTransport()
+	if err := ft.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer ft.Close()
+
+	if err := ft.Write([]byte(`{"type":"control_request","request_id":"req_1","request":"initialize"}`)); err == nil {
+		t.Fatal("Write of a malformed control request succeeded")
+	}
+	select {
+	case err := <-ft.Errors():
+		if err == nil || ft.Err() == nil {
+			t.Errorf("transport error = %v, Err() = %v", err, ft.Err())
+		}
+	case <-ctx.Done():
+		t.Fatal("malformed control request did not fail the transport")
+	}
+}
@@ -2,16 +2,49 @@ package clawde
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
 	"sync"
+	"time"
 )
 
+// newSessionID returns a random version 4 UUID.
+func newSessionID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// sessionFlags are CLI flags that pick the session to use.
+var sessionFlags = []string{"session-id", "resume", "continue", "fork-session"}
+
+// choosesSession reports whether extra CLI arguments already pick a session.
+func choosesSession(extraArgs map[string]string) bool {
+	for _, flag := range sessionFlags {
+		if _, ok := extraArgs[flag]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultTimeoutGracePeriod is used when Options.TimeoutGracePeriod is not set.
+const defaultTimeoutGracePeriod = 5 * time.Second
+
 // Client provides a high-level interface for interacting with Claude.
 type Client struct {
 	opts      *Options
 	transport Transport
 	query     *QueryHandler
+	turns     *turnQueue
 	mu        sync.RWMutex
 	connected bool
+
+	// Settings of the live session, changed by SetPermissionMode and SetModel.
+	permissionMode PermissionMode
+	model          string
 }
 
 // NewClient creates a new Claude client.
@@ -31,9 +64,25 @@ func (c *Client) Connect(ctx context.Context) error {
 	if c.connected {
 		return ErrAlreadyConnected
 	}
+	if err := c.opts.validate(); err != nil {
+		return err
+	}
+
+	// Start a fresh session unless one was chosen. The generated ID is
+	// only given to this connection, so c.opts stays as configured.
+	opts := c.opts
+	if opts.SessionID == "" && opts.ResumeConversation == "" && !choosesSession(opts.ExtraArgs) {
+		connOpts := *c.opts
+		connOpts.SessionID = newSessionID()
+		opts = &connOpts
+	}
 
 	// Create transport
-	c.transport = NewSubprocessTransport(c.opts)
+	transport, err := newTransport(opts)
+	if err != nil {
+		return err
+	}
+	c.transport = transport
 
 	// Start transport
 	if err := c.transport.Start(ctx); err != nil {
@@ -41,7 +90,7 @@ func (c *Client) Connect(ctx context.Context) error {
 	}
 
 	// Create and start query handler
-	c.query = NewQueryHandler(c.transport, c.opts)
+	c.query = NewQueryHandler(c.transport, opts)
 	if err := c.query.Start(ctx); err != nil {
 		c.transport.Close()
 		return err
@@ -53,12 +102,60 @@ func (c *Client) Connect(ctx context.Context) error {
 		return err
 	}
 
+	// Route messages to the turn they answer
+	c.turns = newTurnQueue(c.opts)
+	go c.turns.route(c.query)
+
+	c.permissionMode = c.opts.PermissionMode
+	c.model = c.opts.Model
 	c.connected = true
 	return nil
 }
 
-// Query sends a prompt and returns a stream of responses.
+// Query sends a prompt and returns a stream of the responses to it.
+// If an earlier turn is still running, the CLI answers the prompt once that
+// turn has finished and the stream yields nothing until then. Closing a
+// stream before its result discards the rest of its turn.
 func (c *Client) Query(ctx context.Context, prompt string) (*Stream, error) {
+	t, err := c.sendTurn(func(q *QueryHandler) error { return q.SendPrompt(prompt) }, true)
+	if err != nil {
+		return nil, err
+	}
+	return c.streamTurn(ctx, t)
+}
+
+// QueryBlocks sends a prompt made of content blocks, such as text, images
+// and documents, and returns a stream of the responses to it.
+// Blocks are validated before anything is sent.
+func (c *Client) QueryBlocks(ctx context.Context, blocks ...ContentBlock) (*Stream, error) {
+	t, err := c.sendTurn(func(q *QueryHandler) error { return q.SendBlocks(blocks) }, true)
+	if err != nil {
+		return nil, err
+	}
+	return c.streamTurn(ctx, t)
+}
+
+// UserInput is a user message fed to QueryStream.
+type UserInput struct {
+	// Text is the message text.
+	Text string
+
+	// Blocks replaces Text with content blocks, such as text, images and documents.
+	Blocks []ContentBlock
+
+	// ParentToolUseID ties the message to a tool use, e.g. of a subagent.
+	ParentToolUseID string
+}
+
+// QueryStream keeps the conversation open and sends each message from
+// inputs as it arrives. The returned stream yields the responses to every
+// message in order, including each turn's result, and ends once inputs is
+// closed and the last turn has finished. Options.Timeout is not applied.
+//
+// A turn ending with an error result does not end the stream: Stream.TurnErr
+// reports it while the result is current, and Stream.Err reports the first
+// one once the stream ends. Stream.Usage adds up all turns.
+func (c *Client) QueryStream(ctx context.Context, inputs <-chan UserInput) (*Stream, error) {
 	c.mu.RLock()
 	if !c.connected {
 		c.mu.RUnlock()
@@ -66,52 +163,287 @@ func (c *Client) Query(ctx context.Context, prompt string) (*Stream, error) {
 	}
 	c.mu.RUnlock()
 
-	// Send the prompt
-	if err := c.query.SendPrompt(prompt); err != nil {
-		return nil, err
+	msgCh := make(chan Message)
+	errCh := make(chan error, 1)
+	stream := NewStream(ctx, msgCh, errCh)
+	stream.multiTurn = true
+	ctx = stream.ctx
+
+	// Send inputs as they arrive, handing their turns to the forwarder in order
+	turns := make(chan *turn, 16)
+	go func() {
+		defer close(turns)
+		for {
+			select {
+			case in, ok := <-inputs:
+				if !ok {
+					return
+				}
+				t, err := c.sendTurn(func(q *QueryHandler) error { return q.SendInput(in) }, true)
+				if err != nil {
+					select {
+					case errCh <- err:
+					case <-ctx.Done():
+					}
+					return
+				}
+				select {
+				case turns <- t:
+				case <-ctx.Done():
+					t.abandon()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Forward each turn's messages and errors to the stream
+	go func() {
+		defer close(msgCh)
+		for t := range turns {
+			if !forwardTurn(ctx, t, msgCh, errCh) {
+				t.abandon()
+				for t := range turns {
+					t.abandon()
+				}
+				return
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// forwardTurn copies a turn's messages and errors until it ends. It returns
+// false if ctx is done first.
+func forwardTurn(ctx context.Context, t *turn, msgCh chan<- Message, errCh chan<- error) bool {
+	for {
+		select {
+		case msg, ok := <-t.msgCh:
+			if !ok {
+				// Report why a turn was cut off before its result.
+				select {
+				case err := <-t.errCh:
+					select {
+					case errCh <- err:
+					case <-ctx.Done():
+						return false
+					}
+				default:
+				}
+				return true
+			}
+			select {
+			case msgCh <- msg:
+			case <-ctx.Done():
+				return false
+			}
+		case err := <-t.errCh:
+			select {
+			case errCh <- err:
+			case <-ctx.Done():
+				return false
+			}
+		case <-ctx.Done():
+			return false
+		}
 	}
+}
+
+// sendTurn registers a turn and sends its prompt.
+func (c *Client) sendTurn(send func(q *QueryHandler) error, claimed bool) (*turn, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	// Create stream
-	return NewStream(ctx, c.query.Messages(), c.query.Errors()), nil
+	if !c.connected {
+		return nil, ErrNotConnected
+	}
+
+	t, err := c.turns.enqueue(claimed)
+	if err != nil {
+		return nil, err
+	}
+	if err := send(c.query); err != nil {
+		c.turns.remove(t)
+		return nil, err
+	}
+	return t, nil
 }
 
-// Send sends a prompt without waiting for the response.
-func (c *Client) Send(ctx context.Context, prompt string) error {
+// stream returns a Stream over the oldest turn sent without one.
+func (c *Client) stream(ctx context.Context) (*Stream, error) {
 	c.mu.RLock()
 	if !c.connected {
 		c.mu.RUnlock()
-		return ErrNotConnected
+		return nil, ErrNotConnected
 	}
+	t, ok := c.turns.claim()
 	c.mu.RUnlock()
+	if !ok {
+		return nil, ErrNoPendingPrompt
+	}
+
+	return c.streamTurn(ctx, t)
+}
+
+// streamTurn returns a Stream over t, enforcing Options.Timeout.
+func (c *Client) streamTurn(ctx context.Context, t *turn) (*Stream, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.connected {
+		return nil, ErrNotConnected
+	}
+
+	stream := NewStream(ctx, t.msgCh, t.errCh)
+	stream.abandon = t.abandon
+	if c.opts.Timeout > 0 {
+		grace := c.opts.TimeoutGracePeriod
+		if grace <= 0 {
+			grace = defaultTimeoutGracePeriod
+		}
+		turns := c.turns
+		stream.watchTimeout(t.activeCh, c.opts.Timeout, grace, c.query.Interrupt, func() {
+			turns.logf("turns: turn timed out without a result, giving up on it")
+			turns.giveUp(t)
+		})
+	}
+	return stream, nil
+}
+
+// Send sends a prompt without waiting for the response.
+func (c *Client) Send(ctx context.Context, prompt string) error {
+	_, err := c.sendTurn(func(q *QueryHandler) error { return q.SendPrompt(prompt) }, false)
+	return err
+}
 
-	return c.query.SendPrompt(prompt)
+// SendBlocks sends a prompt made of content blocks without waiting for the response.
+func (c *Client) SendBlocks(ctx context.Context, blocks ...ContentBlock) error {
+	_, err := c.sendTurn(func(q *QueryHandler) error { return q.SendBlocks(blocks) }, false)
+	return err
 }
 
-// Receive returns a channel of messages from the current query.
+// Receive returns a channel of messages from the current connection: the
+// responses to prompts sent with Send, in order. Responses read with
+// ReceiveTurn or a Stream are not included. The channel closes when the
+// client is closed or the connection ends. Every call returns the same
+// channel; once Receive has been called, ReceiveTurn finds no prompts.
+//
+// Messages are not dropped: a response nobody reads holds up later turns
+// once 16 of its messages are buffered.
 func (c *Client) Receive(ctx context.Context) <-chan Message {
 	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	if !c.connected || c.query == nil {
-		c.mu.RUnlock()
 		ch := make(chan Message)
 		close(ch)
 		return ch
 	}
-	c.mu.RUnlock()
+	return c.turns.receive(c.query.doneCh)
+}
 
-	return c.query.Messages()
+// ReceiveTurn returns a stream of the response to the oldest prompt sent
+// with Send that nobody reads yet, or ErrNoPendingPrompt if there is none.
+func (c *Client) ReceiveTurn(ctx context.Context) (*Stream, error) {
+	return c.stream(ctx)
 }
 
-// Interrupt sends an interrupt signal to Claude.
+// Interrupt asks Claude to stop the current query and returns once the CLI
+// has acknowledged the request. Use InterruptContext to bound the wait.
 func (c *Client) Interrupt() error {
+	return c.InterruptContext(context.Background())
+}
+
+// InterruptContext is like Interrupt but gives up waiting for the CLI when
+// ctx is done.
+func (c *Client) InterruptContext(ctx context.Context) error {
+	c.mu.RLock()
+	if !c.connected {
+		c.mu.RUnlock()
+		return ErrNotConnected
+	}
+	c.mu.RUnlock()
+
+	return c.query.Interrupt(ctx)
+}
+
+// SessionID returns the ID of the session, as last reported by the CLI.
+// It is known from Connect on, except when Options.ExtraArgs pick the
+// session, and returns "" when the client is not connected.
+func (c *Client) SessionID() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if !c.connected {
+		return ""
+	}
+	return c.query.SessionID()
+}
+
+// ServerInfo returns the session metadata reported by the CLI: available
+// commands and output style after Connect, plus the model, tools and MCP
+// server statuses once the first "init" system message has arrived.
+// It returns nil when the client is not connected.
+func (c *Client) ServerInfo() *InitMessage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.connected {
+		return nil
+	}
+	return c.query.ServerInfo()
+}
+
+// SetPermissionMode changes the permission mode of the live session.
+// Options reports the new mode once the CLI has accepted the change.
+func (c *Client) SetPermissionMode(ctx context.Context, mode PermissionMode) error {
+	c.mu.RLock()
+	if !c.connected {
+		c.mu.RUnlock()
+		return ErrNotConnected
+	}
+	c.mu.RUnlock()
+
+	_, err := c.query.SendControlRequest(ctx, map[string]any{
+		"subtype": "set_permission_mode",
+		"mode":    string(mode),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.permissionMode = mode
+	c.mu.Unlock()
+	return nil
+}
+
+// SetModel changes the model used for subsequent turns of the live session.
+// An empty model switches back to the CLI default.
+// Options reports the new model once the CLI has accepted the change.
+func (c *Client) SetModel(ctx context.Context, model string) error {
+	c.mu.RLock()
+	if !c.connected {
+		c.mu.RUnlock()
 		return ErrNotConnected
 	}
+	c.mu.RUnlock()
+
+	request := map[string]any{"subtype": "set_model"}
+	if model != "" {
+		request["model"] = model
+	}
+	if _, err := c.query.SendControlRequest(ctx, request); err != nil {
+		return err
+	}
 
-	// Send interrupt message
-	return c.transport.Write([]byte(`{"type":"interrupt"}`))
+	c.mu.Lock()
+	c.model = model
+	c.mu.Unlock()
+	return nil
 }
 
 // Close shuts down the client.
@@ -143,7 +475,16 @@ func (c *Client) IsConnected() bool {
 	return c.connected
 }
 
-// Options returns the client options.
+// Options returns a copy of the client options. While the client is
+// connected, PermissionMode and Model are those of the live session.
 func (c *Client) Options() *Options {
-	return c.opts
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	opts := *c.opts
+	if c.connected {
+		opts.PermissionMode = c.permissionMode
+		opts.Model = c.model
+	}
+	return &opts
 }
@@ -0,0 +1,486 @@
+package clawde_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+// connect connects a client to ft and closes it when the test ends. The
+// returned context bounds the test.
+func connect(t *testing.T, ft *clawdetest.Transport, opts ...clawde.Option) (context.Context, *clawde.Client) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	client, err := clawde.NewClient(append([]clawde.Option{clawde.WithTransport(ft)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return ctx, client
+}
+
+// runQuery sends prompt and reads its turn to the end, failing the test if
+// the turn or the script reports an error.
+func runQuery(t *testing.T, ctx context.Context, client *clawde.Client, ft *clawdetest.Transport, prompt string) *clawde.Stream {
+	t.Helper()
+
+	stream, err := client.Query(ctx, prompt)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if err := stream.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if err := ft.Err(); err != nil {
+		t.Fatalf("script: %v", err)
+	}
+	return stream
+}
+
+func TestSetPermissionModeAndModel(t *testing.T) {
+	ft := clawdetest.NewTransport()
+	ft.HandleControl("set_model", func(request json.RawMessage) (any, error) {
+		if strings.Contains(string(request), "bogus") {
+			return nil, errors.New("unknown model")
+		}
+		return map[string]any{}, nil
+	})
+	ctx, client := connect(t, ft, clawde.WithPermissionMode(clawde.PermissionPlan), clawde.WithModel("opus"))
+
+	if err := client.SetPermissionMode(ctx, clawde.PermissionAcceptEdits); err != nil {
+		t.Fatalf("SetPermissionMode: %v", err)
+	}
+	if err := client.SetModel(ctx, "haiku"); err != nil {
+		t.Fatalf("SetModel: %v", err)
+	}
+	if err := client.SetModel(ctx, "bogus"); err == nil {
+		t.Fatal("SetModel(bogus) succeeded, want error")
+	}
+
+	opts := client.Options()
+	if opts.PermissionMode != clawde.PermissionAcceptEdits || opts.Model != "haiku" {
+		t.Errorf("options = %q/%q, want acceptEdits/haiku", opts.PermissionMode, opts.Model)
+	}
+	opts.Model = "changed"
+	if got := client.Options().Model; got != "haiku" {
+		t.Errorf("changing the returned options changed the client: model %q", got)
+	}
+
+	// Options may be read while the session settings change.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = client.Options().Model
+		}
+	}()
+	if err := client.SetModel(ctx, "sonnet"); err != nil {
+		t.Fatalf("SetModel: %v", err)
+	}
+	wg.Wait()
+
+	var requests []map[string]any
+	for _, raw := range ft.ControlRequests()[1:] {
+		var req map[string]any
+		if err := json.Unmarshal(raw, &req); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, req)
+	}
+	if len(requests) != 4 {
+		t.Fatalf("got %d control requests after initialize, want 4", len(requests))
+	}
+	if requests[0]["subtype"] != "set_permission_mode" || requests[0]["mode"] != "acceptEdits" {
+		t.Errorf("request 0 = %v", requests[0])
+	}
+	if requests[1]["subtype"] != "set_model" || requests[1]["model"] != "haiku" {
+		t.Errorf("request 1 = %v", requests[1])
+	}
+
+	// A new connection starts from the configured settings.
+	client.Close()
+	opts = client.Options()
+	if opts.PermissionMode != clawde.PermissionPlan || opts.Model != "opus" {
+		t.Errorf("options after Close = %q/%q, want plan/opus", opts.PermissionMode, opts.Model)
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.SystemWith("init", map[string]any{
+			"model":          "claude-test",
+			"cwd":            "/work",
+			"permissionMode": "plan",
+			"tools":          []string{"Read", "mcp__util__echo"},
+			"mcp_servers":    []any{map[string]any{"name": "util", "status": "connected"}},
+		}),
+		clawdetest.Result("ok"),
+	)
+	ft.HandleControl("initialize", func(request json.RawMessage) (any, error) {
+		return map[string]any{
+			"commands":     []any{map[string]any{"name": "review", "description": "Review code"}},
+			"output_style": "default",
+		}, nil
+	})
+
+	unconnected, err := clawde.NewClient(clawde.WithTransport(ft))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unconnected.ServerInfo() != nil {
+		t.Error("ServerInfo before Connect is non-nil")
+	}
+
+	ctx, client := connect(t, ft)
+	info := client.ServerInfo()
+	if info == nil || len(info.Commands) != 1 || info.Commands[0].Name != "review" {
+		t.Fatalf("ServerInfo after Connect = %+v, want review command", info)
+	}
+
+	stream, err := client.Query(ctx, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var init *clawde.InitMessage
+	for stream.Next() {
+		if sys, ok := stream.Current().(*clawde.SystemMessage); ok && sys.Init != nil {
+			init = sys.Init
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if init == nil || init.SessionID != clawdetest.DefaultSessionID {
+		t.Fatalf("init message = %+v", init)
+	}
+
+	info = client.ServerInfo()
+	if info.Model != "claude-test" || info.PermissionMode != clawde.PermissionPlan || !info.HasTool("mcp__util__echo") {
+		t.Errorf("ServerInfo = %+v", info)
+	}
+	if s, ok := info.MCPServer("util"); !ok || s.Status != "connected" {
+		t.Errorf("MCPServer(util) = %+v, %v", s, ok)
+	}
+	if len(info.Commands) != 1 || info.OutputStyle != "default" {
+		t.Errorf("initialize metadata lost: %+v", info)
+	}
+}
+
+func TestTurnTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		honourCancel bool
+	}{
+		{"result after interrupt", true},
+		{"cli unresponsive", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interrupted := make(chan struct{})
+			script := []clawdetest.Step{
+				clawdetest.WaitForPrompt(),
+				clawdetest.AssistantText("thinking"),
+				clawdetest.StepFunc(func(ctx context.Context, t *clawdetest.Transport) error {
+					select {
+					case <-interrupted:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}),
+			}
+			if !tt.honourCancel {
+				script = append(script, clawdetest.Sleep(time.Minute))
+			}
+			script = append(script, clawdetest.ResultWith(map[string]any{"subtype": "error_during_execution", "is_error": true}))
+			ft := clawdetest.NewTransport(script...)
+			ft.HandleControl("interrupt", func(request json.RawMessage) (any, error) {
+				close(interrupted)
+				return map[string]any{}, nil
+			})
+			ctx, client := connect(t, ft,
+				clawde.WithTimeout(100*time.Millisecond),
+				clawde.WithTimeoutGracePeriod(100*time.Millisecond),
+			)
+
+			stream, err := client.Query(ctx, "go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = stream.Wait()
+			if !errors.Is(err, clawde.ErrTimeout) {
+				t.Fatalf("err = %v, want ErrTimeout", err)
+			}
+
+			var resErr *clawde.ResultError
+			gotResult := errors.As(err, &resErr)
+			if gotResult != tt.honourCancel {
+				t.Errorf("result attached = %v, want %v", gotResult, tt.honourCancel)
+			}
+			if !client.IsConnected() {
+				t.Error("a timed-out turn closed the client")
+			}
+			_, err = client.Query(ctx, "next")
+			if gotResult != (err == nil) {
+				t.Errorf("Query after the timeout: err = %v", err)
+			}
+		})
+	}
+}
+
+func TestTurnTimeoutStartsWhenTurnIsAnswered(t *testing.T) {
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.AssistantText("one"),
+		clawdetest.Sleep(300*time.Millisecond),
+		clawdetest.Result("one"),
+		clawdetest.Reply("two"),
+	)
+	ctx, client := connect(t, ft,
+		clawde.WithTimeout(200*time.Millisecond),
+		clawde.WithTimeoutGracePeriod(time.Second),
+	)
+
+	// The second turn waits behind the first; its timeout must not run
+	// down meanwhile, nor interrupt the first turn.
+	first, err := client.Query(ctx, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.Query(ctx, "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Wait(); !errors.Is(err, clawde.ErrTimeout) {
+		t.Errorf("first turn: err = %v, want ErrTimeout", err)
+	}
+	text, err := second.CollectText()
+	if err != nil || text != "two" || second.Result() == nil {
+		t.Errorf("second turn = %q, %v; want two with a result", text, err)
+	}
+	if n := len(ft.ControlRequests()); n != 2 {
+		t.Errorf("got %d control requests, want initialize and one interrupt", n)
+	}
+}
+
+func TestTurnTimeoutFailsQueuedTurns(t *testing.T) {
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.AssistantText("stuck"),
+		clawdetest.Sleep(time.Minute),
+	)
+	ctx, client := connect(t, ft,
+		clawde.WithTimeout(100*time.Millisecond),
+		clawde.WithTimeoutGracePeriod(100*time.Millisecond),
+	)
+
+	first, err := client.Query(ctx, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.Query(ctx, "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, stream := range map[string]*clawde.Stream{"first": first, "second": second} {
+		if err := stream.Wait(); !errors.Is(err, clawde.ErrTimeout) {
+			t.Errorf("%s turn: err = %v, want ErrTimeout", name, err)
+		}
+	}
+	if !client.IsConnected() {
+		t.Error("a timed-out turn closed the client")
+	}
+}
+
+func TestGeneratedSessionIDPerConnection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var sent []string
+	factory := clawde.WithTransportFactory(func(o *clawde.Options) (clawde.Transport, error) {
+		sent = append(sent, o.SessionID)
+		return clawdetest.NewTransport(), nil
+	})
+	connect := func(client *clawde.Client) {
+		t.Helper()
+		if err := client.Connect(ctx); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		defer client.Close()
+		if got := client.Options().SessionID; got != "" {
+			t.Errorf("Options().SessionID = %q, want it left empty", got)
+		}
+		if got, want := client.SessionID(), sent[len(sent)-1]; got != want {
+			t.Errorf("SessionID = %q, want %q", got, want)
+		}
+	}
+
+	// Each connection of a client starts its own session.
+	client, err := clawde.NewClient(factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(client)
+	connect(client)
+	if len(sent) != 2 || len(sent[0]) != 36 || len(sent[1]) != 36 || sent[0] == sent[1] {
+		t.Errorf("generated session IDs = %q, want two distinct UUIDs", sent)
+	}
+
+	// No ID is generated when the extra arguments pick the session.
+	for _, flag := range []string{"continue", "fork-session"} {
+		sent = nil
+		client, err := clawde.NewClient(factory, clawde.WithExtraArg(flag, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		connect(client)
+		if sent[0] != "" {
+			t.Errorf("with --%s the transport got session ID %q, want none", flag, sent[0])
+		}
+	}
+}
+
+func TestQueryStreamInputs(t *testing.T) {
+	ft := clawdetest.NewTransport(
+		clawdetest.Reply("first answer"),
+		clawdetest.Reply("second answer"),
+	)
+	ctx, client := connect(t, ft)
+
+	inputs := make(chan clawde.UserInput)
+	stream, err := client.QueryStream(ctx, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs <- clawde.UserInput{Text: "first"}
+	var texts []string
+	results := 0
+	for stream.Next() {
+		switch m := stream.Current().(type) {
+		case *clawde.AssistantMessage:
+			texts = append(texts, m.Text())
+		case *clawde.ResultMessage:
+			results++
+			if results == 1 {
+				// Feed the next message only after the first turn finished.
+				inputs <- clawde.UserInput{
+					Blocks:          []clawde.ContentBlock{clawde.NewTextBlock("second")},
+					ParentToolUseID: "tu_1",
+				}
+				close(inputs)
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if results != 2 || strings.Join(texts, ",") != "first answer,second answer" {
+		t.Errorf("got %d results and texts %q", results, texts)
+	}
+
+	prompts := ft.Prompts()
+	if len(prompts) != 2 {
+		t.Fatalf("got %d prompts, want 2", len(prompts))
+	}
+	var second struct {
+		ParentToolUseID string `json:"parent_tool_use_id"`
+		Message         struct {
+			Content []map[string]any `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(prompts[1], &second); err != nil {
+		t.Fatal(err)
+	}
+	if second.ParentToolUseID != "tu_1" || len(second.Message.Content) != 1 || second.Message.Content[0]["text"] != "second" {
+		t.Errorf("second prompt = %s", prompts[1])
+	}
+}
+
+func TestQueryStreamSendError(t *testing.T) {
+	ft := clawdetest.NewTransport(clawdetest.Reply("first answer"))
+	ctx, client := connect(t, ft)
+
+	inputs := make(chan clawde.UserInput, 2)
+	inputs <- clawde.UserInput{Text: "first"}
+	inputs <- clawde.UserInput{Blocks: []clawde.ContentBlock{nil}}
+	stream, err := client.QueryStream(ctx, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Wait(); err == nil || !strings.Contains(err.Error(), "nil content block") {
+		t.Errorf("err = %v, want the send error", err)
+	}
+}
+
+func TestQueryStreamTurnErrors(t *testing.T) {
+	usage := func(in, out int) map[string]any {
+		return map[string]any{"input_tokens": in, "output_tokens": out}
+	}
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.ResultWith(map[string]any{"subtype": "error_max_turns", "is_error": true, "usage": usage(10, 5)}),
+		clawdetest.WaitForPrompt(),
+		clawdetest.ResultWith(map[string]any{"usage": usage(20, 7)}),
+	)
+	ctx, client := connect(t, ft)
+
+	inputs := make(chan clawde.UserInput, 2)
+	inputs <- clawde.UserInput{Text: "first"}
+	inputs <- clawde.UserInput{Text: "second"}
+	close(inputs)
+	stream, err := client.QueryStream(ctx, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var turnErrs []error
+	for stream.Next() {
+		if _, ok := stream.Current().(*clawde.ResultMessage); ok {
+			turnErrs = append(turnErrs, stream.TurnErr())
+		}
+	}
+	if len(turnErrs) != 2 || !errors.Is(turnErrs[0], clawde.ErrMaxTurnsExceeded) || turnErrs[1] != nil {
+		t.Errorf("turn errors = %v, want max turns then nil", turnErrs)
+	}
+	if err := stream.Err(); !errors.Is(err, clawde.ErrMaxTurnsExceeded) {
+		t.Errorf("Err = %v, want ErrMaxTurnsExceeded", err)
+	}
+	if u := stream.Usage(); u.InputTokens != 30 || u.OutputTokens != 12 {
+		t.Errorf("Usage = %+v, want the sum of both turns", u)
+	}
+}
+
+func TestConnectReportsBadOutputSchema(t *testing.T) {
+	for _, schema := range []any{json.RawMessage(`{"type":`), func() {}} {
+		client, err := clawde.NewClient(
+			clawde.WithTransport(clawdetest.NewTransport()),
+			clawde.WithOutputSchema(schema),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.Connect(context.Background())
+		if err == nil || !strings.HasPrefix(err.Error(), "clawde: output schema: ") {
+			t.Errorf("Connect with output schema %T = %v", schema, err)
+		}
+		if client.IsConnected() {
+			client.Close()
+		}
+	}
+}
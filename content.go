@@ -0,0 +1,219 @@
+package clawde
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Limits on content sent in prompts.
+const (
+	// MaxImageSize is the largest image, in bytes, accepted in a prompt.
+	MaxImageSize = 5 * 1024 * 1024
+
+	// MaxDocumentSize is the largest document, in bytes, accepted in a prompt.
+	MaxDocumentSize = 32 * 1024 * 1024
+)
+
+// imageMediaTypes are the image formats accepted in prompts.
+var imageMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// documentMediaTypes are the document formats accepted in prompts.
+var documentMediaTypes = map[string]bool{
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// DocumentBlock represents a document content block, such as a PDF.
+type DocumentBlock struct {
+	Source DocumentSource `json:"source"`
+	Title  string         `json:"title,omitempty"`
+}
+
+func (DocumentBlock) Type() string { return "document" }
+
+// DocumentSource contains document data.
+type DocumentSource struct {
+	Type      string `json:"type"` // "base64" or "text"
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// NewTextBlock returns a text block for use in a prompt.
+func NewTextBlock(text string) *TextBlock {
+	return &TextBlock{Text: text}
+}
+
+// NewImageBlock returns a base64 image block for use in a prompt.
+func NewImageBlock(mediaType string, data []byte) *ImageBlock {
+	return &ImageBlock{Source: ImageSource{
+		Type:      "base64",
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}}
+}
+
+// NewDocumentBlock returns a document block for use in a prompt.
+// Plain text is sent as is, other formats are base64 encoded.
+func NewDocumentBlock(mediaType string, data []byte) *DocumentBlock {
+	if mediaType == "text/plain" {
+		return &DocumentBlock{Source: DocumentSource{Type: "text", MediaType: mediaType, Data: string(data)}}
+	}
+	return &DocumentBlock{Source: DocumentSource{
+		Type:      "base64",
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}}
+}
+
+// ImageFromFile reads an image file into an image block.
+func ImageFromFile(path string) (*ImageBlock, error) {
+	data, mediaType, err := readContentFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return imageFileBlock(path, mediaType, data)
+}
+
+// DocumentFromFile reads a PDF or text file into a document block titled
+// with the file name.
+func DocumentFromFile(path string) (*DocumentBlock, error) {
+	data, mediaType, err := readContentFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return documentFileBlock(path, mediaType, data)
+}
+
+// FileBlock reads a file into an image or document block depending on its type.
+func FileBlock(path string) (ContentBlock, error) {
+	data, mediaType, err := readContentFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if imageMediaTypes[mediaType] {
+		block, err := imageFileBlock(path, mediaType, data)
+		if err != nil {
+			return nil, err
+		}
+		return block, nil
+	}
+	block, err := documentFileBlock(path, mediaType, data)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// imageFileBlock builds the image block of a file that has been read.
+func imageFileBlock(path, mediaType string, data []byte) (*ImageBlock, error) {
+	block := NewImageBlock(mediaType, data)
+	if err := validateContentBlock(block); err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	return block, nil
+}
+
+// documentFileBlock builds the document block of a file that has been read.
+func documentFileBlock(path, mediaType string, data []byte) (*DocumentBlock, error) {
+	block := NewDocumentBlock(mediaType, data)
+	block.Title = filepath.Base(path)
+	if err := validateContentBlock(block); err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	return block, nil
+}
+
+// readContentFile reads a file and detects its media type from the
+// extension, falling back to the content.
+func readContentFile(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("clawde: read content file: %w", err)
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	if mt, _, err := mime.ParseMediaType(mediaType); err == nil {
+		mediaType = mt
+	}
+	return data, mediaType, nil
+}
+
+// validateContentBlock checks that a block can be sent in a prompt.
+func validateContentBlock(block ContentBlock) error {
+	// A nil interface or a typed nil pointer such as (*ImageBlock)(nil).
+	if v := reflect.ValueOf(block); block == nil || v.Kind() == reflect.Pointer && v.IsNil() {
+		return fmt.Errorf("clawde: nil content block")
+	}
+
+	switch b := block.(type) {
+	case *TextBlock:
+		return nil
+
+	case *ImageBlock:
+		if b.Source.Type != "base64" {
+			return nil
+		}
+		if !imageMediaTypes[b.Source.MediaType] {
+			return fmt.Errorf("clawde: unsupported image type %q", b.Source.MediaType)
+		}
+		if n := base64.StdEncoding.DecodedLen(len(b.Source.Data)); n > MaxImageSize {
+			return fmt.Errorf("clawde: image is %d bytes, max %d", n, MaxImageSize)
+		}
+		return nil
+
+	case *DocumentBlock:
+		if !documentMediaTypes[b.Source.MediaType] {
+			return fmt.Errorf("clawde: unsupported document type %q", b.Source.MediaType)
+		}
+		n := len(b.Source.Data)
+		if b.Source.Type == "base64" {
+			n = base64.StdEncoding.DecodedLen(n)
+		}
+		if n > MaxDocumentSize {
+			return fmt.Errorf("clawde: document is %d bytes, max %d", n, MaxDocumentSize)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("clawde: %s blocks cannot be sent in a prompt", block.Type())
+	}
+}
+
+// encodeContentBlocks validates blocks and converts them to the wire format.
+func encodeContentBlocks(blocks []ContentBlock) ([]map[string]any, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("clawde: empty prompt")
+	}
+
+	encoded := make([]map[string]any, 0, len(blocks))
+	for _, block := range blocks {
+		if err := validateContentBlock(block); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(block)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		m["type"] = block.Type()
+		encoded = append(encoded, m)
+	}
+	return encoded, nil
+}
@@ -0,0 +1,109 @@
+package clawde
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough of a PNG file for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestContentBlocksFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	img, err := FileBlock(write("shot.png", pngHeader))
+	if err != nil {
+		t.Fatalf("FileBlock(png): %v", err)
+	}
+	ib, ok := img.(*ImageBlock)
+	if !ok || ib.Source.MediaType != "image/png" || ib.Source.Data != base64.StdEncoding.EncodeToString(pngHeader) {
+		t.Errorf("png block = %#v", img)
+	}
+
+	// Without a known extension the type is sniffed from the content.
+	if _, err := ImageFromFile(write("screenshot", pngHeader)); err != nil {
+		t.Errorf("ImageFromFile(no extension): %v", err)
+	}
+
+	doc, err := FileBlock(write("notes.txt", []byte("hello")))
+	if err != nil {
+		t.Fatalf("FileBlock(txt): %v", err)
+	}
+	db, ok := doc.(*DocumentBlock)
+	if !ok || db.Source.Type != "text" || db.Source.Data != "hello" || db.Title != "notes.txt" {
+		t.Errorf("txt block = %#v", doc)
+	}
+
+	pdf, err := DocumentFromFile(write("spec.pdf", []byte("%PDF-1.7\n")))
+	if err != nil {
+		t.Fatalf("DocumentFromFile(pdf): %v", err)
+	}
+	if pdf.Source.Type != "base64" || pdf.Source.MediaType != "application/pdf" {
+		t.Errorf("pdf block = %#v", pdf)
+	}
+
+	if block, err := FileBlock(write("data.bin", []byte{0, 1, 2})); err == nil || block != nil {
+		t.Errorf("FileBlock(bin) = %#v, %v, want a nil block and an error", block, err)
+	}
+
+	if _, err := ImageFromFile(write("doc.pdf", []byte("%PDF-1.7\n"))); err == nil || !strings.Contains(err.Error(), "unsupported image type") {
+		t.Errorf("ImageFromFile(pdf) error = %v", err)
+	}
+	if _, err := ImageFromFile(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("ImageFromFile(missing) succeeded")
+	}
+}
+
+func TestEncodeContentBlocks(t *testing.T) {
+	huge := NewImageBlock("image/png", bytes.Repeat([]byte{0}, MaxImageSize+1))
+
+	tests := []struct {
+		name    string
+		blocks  []ContentBlock
+		wantErr string
+	}{
+		{"text and image", []ContentBlock{NewTextBlock("what is this?"), NewImageBlock("image/png", pngHeader)}, ""},
+		{"empty", nil, "empty prompt"},
+		{"image too large", []ContentBlock{huge}, "max"},
+		{"bad image type", []ContentBlock{NewImageBlock("image/tiff", pngHeader)}, "unsupported image type"},
+		{"bad document type", []ContentBlock{NewDocumentBlock("application/zip", []byte("PK"))}, "unsupported document type"},
+		{"output-only block", []ContentBlock{&ToolUseBlock{ID: "tu_1", Name: "Read"}}, "cannot be sent"},
+		{"nil block", []ContentBlock{nil}, "nil content block"},
+		{"nil text block", []ContentBlock{NewTextBlock("hi"), (*TextBlock)(nil)}, "nil content block"},
+		{"nil image block", []ContentBlock{(*ImageBlock)(nil)}, "nil content block"},
+		{"nil document block", []ContentBlock{(*DocumentBlock)(nil)}, "nil content block"},
+		{"nil output-only block", []ContentBlock{(*ToolUseBlock)(nil)}, "nil content block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeContentBlocks(tt.blocks)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(encoded) != 2 || encoded[0]["type"] != "text" || encoded[0]["text"] != "what is this?" || encoded[1]["type"] != "image" {
+				t.Errorf("encoded = %v", encoded)
+			}
+			source, _ := encoded[1]["source"].(map[string]any)
+			if source["type"] != "base64" || source["media_type"] != "image/png" {
+				t.Errorf("image source = %v", source)
+			}
+		})
+	}
+}
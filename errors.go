@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Common errors returned by the SDK.
@@ -20,6 +21,10 @@ var (
 	// ErrStreamClosed is returned when reading from a closed stream.
 	ErrStreamClosed = errors.New("clawde: stream closed")
 
+	// ErrNoPendingPrompt is returned when reading a response before any
+	// prompt awaiting one was sent.
+	ErrNoPendingPrompt = errors.New("clawde: no prompt awaiting a response")
+
 	// ErrBudgetExceeded is returned when the query exceeds the budget limit.
 	ErrBudgetExceeded = errors.New("clawde: budget exceeded")
 
@@ -31,8 +36,53 @@ var (
 
 	// ErrInterrupted is returned when a query is interrupted.
 	ErrInterrupted = errors.New("clawde: interrupted")
+
+	// ErrExecution is returned when a query ends with an error during execution.
+	ErrExecution = errors.New("clawde: error during execution")
+
+	// ErrStructuredOutputRetries is returned when the agent fails to produce
+	// output matching the output schema.
+	ErrStructuredOutputRetries = errors.New("clawde: structured output retries exceeded")
 )
 
+// ResultError is returned when a query ends with an error result.
+// It wraps one of the sentinel errors above.
+type ResultError struct {
+	Result *ResultMessage
+	Err    error
+}
+
+func (e *ResultError) Error() string {
+	if e.Result.Result != "" && e.Result.Result != e.Err.Error() {
+		return fmt.Sprintf("%v (%s): %s", e.Err, e.Result.Subtype, truncate(e.Result.Result, 200))
+	}
+	return fmt.Sprintf("%v (%s)", e.Err, e.Result.Subtype)
+}
+
+func (e *ResultError) Unwrap() error {
+	return e.Err
+}
+
+// resultError maps an error result to a *ResultError, or returns nil on success.
+func resultError(result *ResultMessage) error {
+	var err error
+	switch {
+	case result.interrupted:
+		err = ErrInterrupted
+	case result.Subtype == "error_max_turns":
+		err = ErrMaxTurnsExceeded
+	case result.Subtype == "error_max_budget_usd":
+		err = ErrBudgetExceeded
+	case result.Subtype == "error_max_structured_output_retries":
+		err = ErrStructuredOutputRetries
+	case strings.HasPrefix(result.Subtype, "error"), result.IsError:
+		err = ErrExecution
+	default:
+		return nil
+	}
+	return &ResultError{Result: result, Err: err}
+}
+
 // ProcessError represents an error from the subprocess.
 type ProcessError struct {
 	ExitCode int
@@ -53,6 +103,40 @@ func (e *ProtocolError) Error() string {
 	return fmt.Sprintf("clawde: protocol error: %s", e.Message)
 }
 
+// ControlError is returned when the CLI answers a control request with an error.
+type ControlError struct {
+	Subtype   string
+	RequestID string
+	Message   string
+}
+
+func (e *ControlError) Error() string {
+	return fmt.Sprintf("clawde: %s request failed: %s", e.Subtype, e.Message)
+}
+
+// ValidationError is returned when a value does not match a JSON Schema.
+type ValidationError struct {
+	Violations []SchemaViolation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("clawde: value does not match schema: %s", strings.Join(msgs, "; "))
+}
+
+// SchemaViolation describes one way a value does not match a JSON Schema.
+type SchemaViolation struct {
+	Path    string `json:"path"` // location of the value, such as "$.items[0].name"
+	Message string `json:"message"`
+}
+
+func (v SchemaViolation) String() string {
+	return v.Path + ": " + v.Message
+}
+
 // ParseError represents an error parsing a message.
 type ParseError struct {
 	Line string
@@ -0,0 +1,53 @@
+package clawde_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestResultSubtypeErrors(t *testing.T) {
+	tests := []struct {
+		subtype string
+		isError bool
+		want    error
+	}{
+		{"success", false, nil},
+		{"error_max_turns", true, clawde.ErrMaxTurnsExceeded},
+		{"error_max_budget_usd", true, clawde.ErrBudgetExceeded},
+		{"error_during_execution", true, clawde.ErrExecution},
+		{"error_max_structured_output_retries", true, clawde.ErrStructuredOutputRetries},
+		{"success", true, clawde.ErrExecution},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subtype, func(t *testing.T) {
+			ft := clawdetest.NewTransport(
+				clawdetest.WaitForPrompt(),
+				clawdetest.AssistantText("partial"),
+				clawdetest.ResultWith(map[string]any{"subtype": tt.subtype, "is_error": tt.isError}),
+			)
+			ctx, client := connect(t, ft)
+
+			stream, err := client.Query(ctx, "go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, err := stream.CollectText()
+			if text != "partial" {
+				t.Errorf("text = %q, want partial", text)
+			}
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil {
+				return
+			}
+			var resErr *clawde.ResultError
+			if !errors.As(err, &resErr) || resErr.Result.Subtype != tt.subtype {
+				t.Errorf("err = %#v, want *ResultError with subtype %s", err, tt.subtype)
+			}
+		})
+	}
+}
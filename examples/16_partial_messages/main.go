@@ -55,7 +55,23 @@ func main() {
 				fmt.Printf("[Thinking] %s...\n", truncate(thinking, 50))
 			}
 		case *clawde.StreamEvent:
-			fmt.Printf("[StreamEvent] type=%s subtype=%s\n", m.Type, m.Subtype)
+			switch ev := m.Data.(type) {
+			case *clawde.ContentBlockStartEvent:
+				fmt.Printf("\n[Block %d] %s\n", ev.Index, ev.Block.Type())
+			case *clawde.ContentBlockDeltaEvent:
+				switch ev.Delta.Type {
+				case clawde.DeltaText:
+					fmt.Print(ev.Delta.Text)
+				case clawde.DeltaThinking:
+					fmt.Print(ev.Delta.Thinking)
+				case clawde.DeltaInputJSON:
+					fmt.Print(ev.Delta.PartialJSON)
+				}
+			case *clawde.MessageStopEvent:
+				// The partial message now holds everything streamed so far.
+				partial := stream.Partial()
+				fmt.Printf("\n[Partial] %d blocks, stop_reason=%s\n", len(partial.Content), partial.StopReason)
+			}
 		case *clawde.SystemMessage:
 			fmt.Printf("[System] type=%s subtype=%s\n", m.Type, m.Subtype)
 		case *clawde.ResultMessage:
@@ -108,6 +108,9 @@ func main() {
 	postToolHook := clawde.MatchTool(".*", func(ctx context.Context, input *clawde.HookInput) (*clawde.HookOutput, error) {
 		return tracker.PostToolUseHook(ctx, input)
 	})
+	toolFailureHook := clawde.MatchTool(".*", func(ctx context.Context, input *clawde.HookInput) (*clawde.HookOutput, error) {
+		return tracker.PostToolUseFailureHook(ctx, input)
+	})
 
 	// Create client with lead agent configuration
 	client, err := clawde.NewClient(
@@ -119,6 +122,7 @@ func main() {
 		clawde.WithAgents(agents),
 		clawde.WithHook(clawde.HookPreToolUse, preToolHook),
 		clawde.WithHook(clawde.HookPostToolUse, postToolHook),
+		clawde.WithHook(clawde.HookPostToolUseFailure, toolFailureHook),
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,154 @@
+package clawde_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestHookCallbacksRoutedPerMatcher(t *testing.T) {
+	calls := make(map[string]int)
+	record := func(name string) clawde.HookCallback {
+		return func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+			calls[name+":"+in.ToolName]++
+			return clawde.ContinueHook(), nil
+		}
+	}
+
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "Edit"}),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "Read"}),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "mcp__docs__search"}),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "BashOutput"}),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "Bash"}),
+		// A callback the CLI calls for a tool its matcher rejects is skipped.
+		clawdetest.HookCallback("hook_PreToolUse_0_0", map[string]any{"hook_event_name": "PreToolUse", "tool_name": "Read"}),
+		clawdetest.Result("ok"),
+	)
+	ctx, client := connect(t, ft,
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchTool("Write|Edit", record("edit"))),
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchToolCallbacks("Read", record("read1"), record("read2"))),
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchTool("mcp__docs__.*", record("mcp"))),
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchToolWithTimeout("Bash", 90*time.Second, record("bash"))),
+	)
+	runQuery(t, ctx, client, ft, "go")
+
+	want := map[string]int{"edit:Edit": 1, "read1:Read": 1, "read2:Read": 1, "mcp:mcp__docs__search": 1, "bash:Bash": 1}
+	if len(calls) != len(want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	for k, n := range want {
+		if calls[k] != n {
+			t.Errorf("calls[%q] = %d, want %d", k, calls[k], n)
+		}
+	}
+
+	// The CLI takes hook timeouts in seconds.
+	var init struct {
+		Hooks map[string][]struct {
+			Matcher string   `json:"matcher"`
+			Timeout *float64 `json:"timeout"`
+		} `json:"hooks"`
+	}
+	if err := json.Unmarshal(ft.InitializeRequest(), &init); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range init.Hooks["PreToolUse"] {
+		switch {
+		case m.Matcher == "Bash" && (m.Timeout == nil || *m.Timeout != 90):
+			t.Errorf("timeout of Bash matcher = %v, want 90", m.Timeout)
+		case m.Matcher != "Bash" && m.Timeout != nil:
+			t.Errorf("timeout of %s matcher = %v, want none", m.Matcher, *m.Timeout)
+		}
+	}
+}
+
+func TestHookOutputWireFormat(t *testing.T) {
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "Bash"}),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "Edit"}),
+		clawdetest.Hook("PreToolUse", map[string]any{"tool_name": "Write"}),
+		clawdetest.Result("ok"),
+	)
+	ctx, client := connect(t, ft,
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchTool("Bash", func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+			out := clawde.DenyToolHook("use the Read tool instead")
+			out.SystemMessage = "blocked cat"
+			out.SuppressOutput = true
+			return out, nil
+		})),
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchTool("Edit", func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+			return &clawde.HookOutput{Continue: true, ModifiedInput: json.RawMessage(`{"file_path":"/tmp/safe"}`)}, nil
+		})),
+		clawde.WithHook(clawde.HookPreToolUse, clawde.MatchTool("Write", func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+			return nil, errors.New("disk full")
+		})),
+	)
+	runQuery(t, ctx, client, ft, "go")
+
+	want := []string{
+		`{"continue":true,"suppressOutput":true,"systemMessage":"blocked cat","hookSpecificOutput":{"hookEventName":"PreToolUse","permissionDecision":"deny","permissionDecisionReason":"use the Read tool instead"}}`,
+		// ModifiedInput is sent as PreToolUseOutput.UpdatedInput.
+		`{"continue":true,"hookSpecificOutput":{"hookEventName":"PreToolUse","updatedInput":{"file_path":"/tmp/safe"}}}`,
+		// A callback error blocks the tool call.
+		`{"continue":false,"decision":"block","reason":"disk full"}`,
+	}
+	responses := ft.Responses()
+	if len(responses) != len(want) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(want))
+	}
+	for i, r := range responses {
+		if string(r.Response) != want[i] {
+			t.Errorf("response %d =\n%s\nwant\n%s", i, r.Response, want[i])
+		}
+	}
+}
+
+func TestLifecycleHookEvents(t *testing.T) {
+	var failure *clawde.HookInput
+	ft := clawdetest.NewTransport(
+		clawdetest.Hook("SessionStart", map[string]any{"source": "startup"}),
+		clawdetest.Hook("PostToolUseFailure", map[string]any{
+			"tool_name":   "Bash",
+			"tool_use_id": "tu_1",
+			"error":       "exit status 1",
+		}),
+		clawdetest.WaitForPrompt(),
+		clawdetest.Result("ok"),
+	)
+	ctx, client := connect(t, ft,
+		clawde.WithHook(clawde.HookSessionStart, clawde.MatchAll(func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+			return clawde.SessionContextHook("source=" + in.Source), nil
+		})),
+		clawde.WithHook(clawde.HookPostToolUseFailure, clawde.MatchTool("Bash", func(ctx context.Context, in *clawde.HookInput) (*clawde.HookOutput, error) {
+			failure = in
+			return clawde.ContinueHook(), nil
+		})),
+	)
+	runQuery(t, ctx, client, ft, "go")
+
+	responses := ft.Responses()
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2", len(responses))
+	}
+	var start struct {
+		HookSpecificOutput map[string]any `json:"hookSpecificOutput"`
+	}
+	if err := responses[0].Decode(&start); err != nil {
+		t.Fatal(err)
+	}
+	if start.HookSpecificOutput["hookEventName"] != "SessionStart" || start.HookSpecificOutput["additionalContext"] != "source=startup" {
+		t.Errorf("SessionStart output = %v", start.HookSpecificOutput)
+	}
+
+	if failure == nil || failure.Error != "exit status 1" {
+		t.Errorf("PostToolUseFailure input = %+v, want error", failure)
+	}
+}
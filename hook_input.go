@@ -0,0 +1,209 @@
+package clawde
+
+// PreToolUseInput is the input of a PreToolUse hook.
+type PreToolUseInput struct {
+	ToolName  string
+	ToolUseID string
+	ToolInput map[string]any
+}
+
+// PostToolUseInput is the input of a PostToolUse hook.
+type PostToolUseInput struct {
+	ToolName     string
+	ToolUseID    string
+	ToolInput    map[string]any
+	ToolResponse map[string]any
+}
+
+// PostToolUseFailureInput is the input of a PostToolUseFailure hook.
+type PostToolUseFailureInput struct {
+	ToolName    string
+	ToolUseID   string
+	ToolInput   map[string]any
+	Error       string
+	IsInterrupt bool
+}
+
+// UserPromptSubmitInput is the input of a UserPromptSubmit hook.
+type UserPromptSubmitInput struct {
+	Prompt string
+}
+
+// StopInput is the input of a Stop hook.
+type StopInput struct {
+	StopHookActive bool
+}
+
+// SubagentStartInput is the input of a SubagentStart hook.
+type SubagentStartInput struct {
+	AgentID   string
+	AgentType string
+}
+
+// SubagentStopInput is the input of a SubagentStop hook.
+type SubagentStopInput struct {
+	StopHookActive      bool
+	AgentID             string
+	AgentTranscriptPath string
+}
+
+// PreCompactInput is the input of a PreCompact hook.
+type PreCompactInput struct {
+	// Trigger is "manual" or "auto".
+	Trigger            string
+	CustomInstructions string
+}
+
+// NotificationInput is the input of a Notification hook.
+type NotificationInput struct {
+	Message          string
+	Title            string
+	NotificationType string
+}
+
+// SessionStartInput is the input of a SessionStart hook.
+type SessionStartInput struct {
+	// Source is "startup", "resume", "clear" or "compact".
+	Source string
+}
+
+// SessionEndInput is the input of a SessionEnd hook.
+type SessionEndInput struct {
+	Reason string
+}
+
+// PermissionRequestInput is the input of a PermissionRequest hook.
+type PermissionRequestInput struct {
+	ToolName    string
+	ToolInput   map[string]any
+	Suggestions []PermissionUpdate
+}
+
+// PreToolUse returns the PreToolUse fields, or false for other events.
+func (h *HookInput) PreToolUse() (*PreToolUseInput, bool) {
+	if h.HookEventName != HookPreToolUse {
+		return nil, false
+	}
+	return &PreToolUseInput{
+		ToolName:  h.ToolName,
+		ToolUseID: h.ToolUseID,
+		ToolInput: h.ToolInputMap,
+	}, true
+}
+
+// PostToolUse returns the PostToolUse fields, or false for other events.
+func (h *HookInput) PostToolUse() (*PostToolUseInput, bool) {
+	if h.HookEventName != HookPostToolUse {
+		return nil, false
+	}
+	return &PostToolUseInput{
+		ToolName:     h.ToolName,
+		ToolUseID:    h.ToolUseID,
+		ToolInput:    h.ToolInputMap,
+		ToolResponse: h.ToolResult,
+	}, true
+}
+
+// PostToolUseFailure returns the PostToolUseFailure fields, or false for other events.
+func (h *HookInput) PostToolUseFailure() (*PostToolUseFailureInput, bool) {
+	if h.HookEventName != HookPostToolUseFailure {
+		return nil, false
+	}
+	return &PostToolUseFailureInput{
+		ToolName:    h.ToolName,
+		ToolUseID:   h.ToolUseID,
+		ToolInput:   h.ToolInputMap,
+		Error:       h.Error,
+		IsInterrupt: h.IsInterrupt,
+	}, true
+}
+
+// UserPromptSubmit returns the UserPromptSubmit fields, or false for other events.
+func (h *HookInput) UserPromptSubmit() (*UserPromptSubmitInput, bool) {
+	if h.HookEventName != HookUserPromptSubmit {
+		return nil, false
+	}
+	return &UserPromptSubmitInput{Prompt: h.Prompt}, true
+}
+
+// Stop returns the Stop fields, or false for other events.
+func (h *HookInput) Stop() (*StopInput, bool) {
+	if h.HookEventName != HookStop {
+		return nil, false
+	}
+	return &StopInput{StopHookActive: h.StopHookActive}, true
+}
+
+// SubagentStart returns the SubagentStart fields, or false for other events.
+func (h *HookInput) SubagentStart() (*SubagentStartInput, bool) {
+	if h.HookEventName != HookSubagentStart {
+		return nil, false
+	}
+	return &SubagentStartInput{
+		AgentID:   h.AgentID,
+		AgentType: h.AgentType,
+	}, true
+}
+
+// SubagentStop returns the SubagentStop fields, or false for other events.
+func (h *HookInput) SubagentStop() (*SubagentStopInput, bool) {
+	if h.HookEventName != HookSubagentStop {
+		return nil, false
+	}
+	return &SubagentStopInput{
+		StopHookActive:      h.StopHookActive,
+		AgentID:             h.AgentID,
+		AgentTranscriptPath: h.AgentTranscriptPath,
+	}, true
+}
+
+// PreCompact returns the PreCompact fields, or false for other events.
+func (h *HookInput) PreCompact() (*PreCompactInput, bool) {
+	if h.HookEventName != HookPreCompact {
+		return nil, false
+	}
+	return &PreCompactInput{
+		Trigger:            h.Trigger,
+		CustomInstructions: h.CustomInstructions,
+	}, true
+}
+
+// Notification returns the Notification fields, or false for other events.
+func (h *HookInput) Notification() (*NotificationInput, bool) {
+	if h.HookEventName != HookNotification {
+		return nil, false
+	}
+	return &NotificationInput{
+		Message:          h.Message,
+		Title:            h.Title,
+		NotificationType: h.NotificationType,
+	}, true
+}
+
+// SessionStart returns the SessionStart fields, or false for other events.
+func (h *HookInput) SessionStart() (*SessionStartInput, bool) {
+	if h.HookEventName != HookSessionStart {
+		return nil, false
+	}
+	return &SessionStartInput{Source: h.Source}, true
+}
+
+// SessionEnd returns the SessionEnd fields, or false for other events.
+func (h *HookInput) SessionEnd() (*SessionEndInput, bool) {
+	if h.HookEventName != HookSessionEnd {
+		return nil, false
+	}
+	return &SessionEndInput{Reason: h.Reason}, true
+}
+
+// PermissionRequest returns the PermissionRequest fields, or false for other events.
+func (h *HookInput) PermissionRequest() (*PermissionRequestInput, bool) {
+	if h.HookEventName != HookPermissionRequest {
+		return nil, false
+	}
+	return &PermissionRequestInput{
+		ToolName:    h.ToolName,
+		ToolInput:   h.ToolInputMap,
+		Suggestions: h.PermissionSuggestions,
+	}, true
+}
@@ -3,6 +3,9 @@ package clawde
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -27,28 +30,134 @@ const (
 
 	// HookPreCompact is called before context compaction.
 	HookPreCompact HookEvent = "PreCompact"
+
+	// HookPostToolUseFailure is called after a tool execution fails.
+	HookPostToolUseFailure HookEvent = "PostToolUseFailure"
+
+	// HookNotification is called when Claude Code sends a notification.
+	HookNotification HookEvent = "Notification"
+
+	// HookSessionStart is called when a session starts or resumes.
+	HookSessionStart HookEvent = "SessionStart"
+
+	// HookSessionEnd is called when a session ends.
+	HookSessionEnd HookEvent = "SessionEnd"
+
+	// HookSubagentStart is called when a subagent starts.
+	HookSubagentStart HookEvent = "SubagentStart"
+
+	// HookPermissionRequest is called when a permission dialog would be shown.
+	HookPermissionRequest HookEvent = "PermissionRequest"
 )
 
 // HookMatcher defines which tools a hook applies to and its callback.
 type HookMatcher struct {
-	// ToolName is the tool to match ("*" for all tools).
+	// ToolName is a tool name pattern using the Claude Code settings syntax:
+	// "*" or "" matches all tools, "Write|Edit" matches either tool exactly,
+	// and anything else is a regular expression such as "mcp__github__.*".
+	// The CLI evaluates regular expressions with JavaScript semantics. The SDK
+	// checks those in the syntax Go shares with JavaScript when connecting;
+	// ones using lookaround or backreferences, such as "^(?!mcp__)", are
+	// passed to the CLI unchecked.
 	ToolName string
 
 	// Callback is the function to call when the hook matches.
 	Callback HookCallback
 
-	// Timeout is the maximum duration for the hook callback.
+	// Callbacks are additional functions to call when the hook matches.
+	// Each callback is registered with the CLI under its own ID.
+	Callbacks []HookCallback
+
+	// Timeout is the maximum duration for each hook callback. It is also
+	// sent to the CLI, in seconds, as the timeout of the matcher.
 	Timeout time.Duration
 }
 
+// callbacks returns Callback followed by Callbacks, skipping nil entries.
+func (m HookMatcher) callbacks() []HookCallback {
+	var cbs []HookCallback
+	if m.Callback != nil {
+		cbs = append(cbs, m.Callback)
+	}
+	for _, cb := range m.Callbacks {
+		if cb != nil {
+			cbs = append(cbs, cb)
+		}
+	}
+	return cbs
+}
+
+// toolPattern is a compiled HookMatcher.ToolName.
+type toolPattern struct {
+	all   bool // matches every tool, or is left to the CLI to evaluate
+	names []string
+	re    *regexp.Regexp
+}
+
+// simpleToolPattern matches patterns made only of tool names and alternation.
+var simpleToolPattern = regexp.MustCompile(`^[A-Za-z0-9_|]+$`)
+
+// jsOnlySyntax finds JavaScript regular expression syntax that Go does not
+// support: lookaround, backreferences and \u escapes.
+var jsOnlySyntax = regexp.MustCompile(`\(\?<?[=!]|\\[1-9]|\\k<|\\u`)
+
+// compileToolPattern compiles a hook matcher pattern: "" or "*" match every
+// tool, names separated by "|" match exactly, and anything else must be a
+// valid regular expression. Patterns using JavaScript-only syntax are left
+// to the CLI, which applies every pattern itself.
+func compileToolPattern(pattern string) (*toolPattern, error) {
+	if pattern == "" || pattern == "*" || jsOnlySyntax.MatchString(pattern) {
+		return &toolPattern{all: true}, nil
+	}
+	if simpleToolPattern.MatchString(pattern) {
+		return &toolPattern{names: strings.Split(pattern, "|")}, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("clawde: invalid hook matcher %q: %w", pattern, err)
+	}
+	return &toolPattern{re: re}, nil
+}
+
+// match reports whether the pattern matches toolName.
+// Events that carry no tool name match every pattern.
+func (p *toolPattern) match(toolName string) bool {
+	if p.all || toolName == "" {
+		return true
+	}
+	if p.re != nil {
+		return p.re.MatchString(toolName)
+	}
+	for _, name := range p.names {
+		if name == toolName {
+			return true
+		}
+	}
+	return false
+}
+
 // HookCallback is called when a hook event occurs.
 type HookCallback func(ctx context.Context, input *HookInput) (*HookOutput, error)
 
 // HookInput contains information about the hook event.
+// Fields that do not apply to the event are left empty; the typed accessors
+// in hook_input.go return just the fields of one event.
 type HookInput struct {
+	// HookEventName is the event that triggered the hook.
+	HookEventName HookEvent `json:"hook_event_name"`
+
 	// SessionID is the current session ID.
 	SessionID string `json:"session_id"`
 
+	// TranscriptPath is the path to the session transcript.
+	TranscriptPath string `json:"transcript_path,omitempty"`
+
+	// Cwd is the working directory of the session.
+	Cwd string `json:"cwd,omitempty"`
+
+	// PermissionMode is the session's permission mode.
+	PermissionMode PermissionMode `json:"permission_mode,omitempty"`
+
 	// ToolName is the name of the tool (for tool hooks).
 	ToolName string `json:"tool_name"`
 
@@ -69,6 +178,7 @@ type HookInput struct {
 	ToolOutput json.RawMessage `json:"tool_response"`
 
 	// ToolResult is the parsed tool result as a map for convenience.
+	// Results that are not JSON objects are stored under "content".
 	ToolResult map[string]interface{} `json:"-"`
 
 	// Prompt is the user prompt (UserPromptSubmit only).
@@ -76,6 +186,86 @@ type HookInput struct {
 
 	// StopReason is the reason for stopping (Stop/SubagentStop only).
 	StopReason string `json:"stop_reason,omitempty"`
+
+	// StopHookActive is true when the agent is already continuing because of
+	// a stop hook (Stop/SubagentStop only).
+	StopHookActive bool `json:"stop_hook_active,omitempty"`
+
+	// Error describes the failure (PostToolUseFailure only).
+	Error string `json:"error,omitempty"`
+
+	// IsInterrupt is true when the failure was caused by an interrupt
+	// (PostToolUseFailure only).
+	IsInterrupt bool `json:"is_interrupt,omitempty"`
+
+	// Message is the notification text (Notification only).
+	Message string `json:"message,omitempty"`
+
+	// Title is the notification title (Notification only).
+	Title string `json:"title,omitempty"`
+
+	// NotificationType classifies the notification (Notification only).
+	NotificationType string `json:"notification_type,omitempty"`
+
+	// Source is how the session started: "startup", "resume", "clear" or
+	// "compact" (SessionStart only).
+	Source string `json:"source,omitempty"`
+
+	// Reason is why the session ended (SessionEnd only).
+	Reason string `json:"reason,omitempty"`
+
+	// AgentID is the subagent ID (SubagentStart/SubagentStop only).
+	AgentID string `json:"agent_id,omitempty"`
+
+	// AgentType is the subagent type (SubagentStart only).
+	AgentType string `json:"agent_type,omitempty"`
+
+	// AgentTranscriptPath is the subagent's transcript (SubagentStop only).
+	AgentTranscriptPath string `json:"agent_transcript_path,omitempty"`
+
+	// Trigger is "manual" or "auto" (PreCompact only).
+	Trigger string `json:"trigger,omitempty"`
+
+	// CustomInstructions are the user's /compact instructions (PreCompact only).
+	CustomInstructions string `json:"custom_instructions,omitempty"`
+
+	// PermissionSuggestions are the updates the permission dialog would
+	// offer (PermissionRequest only).
+	PermissionSuggestions []PermissionUpdate `json:"permission_suggestions,omitempty"`
+}
+
+// UnmarshalJSON decodes a hook input and populates ToolInputMap and ToolResult.
+func (h *HookInput) UnmarshalJSON(data []byte) error {
+	type plain HookInput
+	if err := json.Unmarshal(data, (*plain)(h)); err != nil {
+		return err
+	}
+
+	h.ToolInputMap = nil
+	if len(h.ToolInput) > 0 {
+		json.Unmarshal(h.ToolInput, &h.ToolInputMap)
+	}
+
+	h.ToolResult = nil
+	if len(h.ToolOutput) > 0 && string(h.ToolOutput) != "null" {
+		if err := json.Unmarshal(h.ToolOutput, &h.ToolResult); err != nil {
+			var content any
+			if json.Unmarshal(h.ToolOutput, &content) == nil {
+				h.ToolResult = map[string]interface{}{"content": content}
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeToolInput unmarshals the tool input into v.
+func (h *HookInput) DecodeToolInput(v any) error {
+	return json.Unmarshal(h.ToolInput, v)
+}
+
+// DecodeToolResponse unmarshals the tool response into v.
+func (h *HookInput) DecodeToolResponse(v any) error {
+	return json.Unmarshal(h.ToolOutput, v)
 }
 
 // Command extracts the command from a Bash tool input.
@@ -121,8 +311,128 @@ type HookOutput struct {
 	// StopReason is the reason for stopping (Stop hooks only).
 	StopReason string
 
-	// ModifiedInput allows modifying the tool input.
+	// ModifiedInput allows modifying the tool input (PreToolUse only).
+	// It is sent as PreToolUseOutput.UpdatedInput when SpecificOutput is nil.
 	ModifiedInput json.RawMessage
+
+	// SuppressOutput hides the hook's output from the transcript.
+	SuppressOutput bool
+
+	// SystemMessage is a warning shown to the user.
+	SystemMessage string
+
+	// SpecificOutput carries output specific to the hook event.
+	SpecificOutput HookSpecificOutput
+}
+
+// HookSpecificOutput is output that only applies to one hook event.
+type HookSpecificOutput interface {
+	hookEvent() HookEvent
+}
+
+// PermissionDecision is a PreToolUse hook's decision about a tool call.
+type PermissionDecision string
+
+const (
+	// PermissionDecisionAllow approves the tool call without asking.
+	PermissionDecisionAllow PermissionDecision = "allow"
+
+	// PermissionDecisionDeny prevents the tool call.
+	PermissionDecisionDeny PermissionDecision = "deny"
+
+	// PermissionDecisionAsk asks the user to confirm the tool call.
+	PermissionDecisionAsk PermissionDecision = "ask"
+)
+
+// PreToolUseOutput controls whether a tool call proceeds.
+type PreToolUseOutput struct {
+	// PermissionDecision allows, denies or asks about the tool call.
+	PermissionDecision PermissionDecision `json:"permissionDecision,omitempty"`
+
+	// PermissionDecisionReason is shown to the user for allow and ask,
+	// and to Claude for deny.
+	PermissionDecisionReason string `json:"permissionDecisionReason,omitempty"`
+
+	// UpdatedInput replaces the tool input.
+	UpdatedInput json.RawMessage `json:"updatedInput,omitempty"`
+}
+
+func (PreToolUseOutput) hookEvent() HookEvent { return HookPreToolUse }
+
+// PostToolUseOutput adds context after a tool runs.
+type PostToolUseOutput struct {
+	// AdditionalContext is shown to Claude along with the tool result.
+	AdditionalContext string `json:"additionalContext,omitempty"`
+}
+
+func (PostToolUseOutput) hookEvent() HookEvent { return HookPostToolUse }
+
+// UserPromptSubmitOutput adds context to a submitted prompt.
+type UserPromptSubmitOutput struct {
+	// AdditionalContext is added to the conversation with the prompt.
+	AdditionalContext string `json:"additionalContext,omitempty"`
+}
+
+func (UserPromptSubmitOutput) hookEvent() HookEvent { return HookUserPromptSubmit }
+
+// SessionStartOutput adds context at the start of a session.
+type SessionStartOutput struct {
+	// AdditionalContext is added to the conversation context.
+	AdditionalContext string `json:"additionalContext,omitempty"`
+}
+
+func (SessionStartOutput) hookEvent() HookEvent { return HookSessionStart }
+
+// SubagentStartOutput adds context to a starting subagent.
+type SubagentStartOutput struct {
+	// AdditionalContext is added to the subagent's context.
+	AdditionalContext string `json:"additionalContext,omitempty"`
+}
+
+func (SubagentStartOutput) hookEvent() HookEvent { return HookSubagentStart }
+
+// PostToolUseFailureOutput adds context after a tool failure.
+type PostToolUseFailureOutput struct {
+	// AdditionalContext is shown to Claude along with the failure.
+	AdditionalContext string `json:"additionalContext,omitempty"`
+}
+
+func (PostToolUseFailureOutput) hookEvent() HookEvent { return HookPostToolUseFailure }
+
+// PermissionRequestOutput answers a permission dialog on the user's behalf.
+type PermissionRequestOutput struct {
+	// Decision is the answer to the permission request.
+	Decision PermissionRequestDecision `json:"decision"`
+}
+
+func (PermissionRequestOutput) hookEvent() HookEvent { return HookPermissionRequest }
+
+// PermissionRequestDecision is the answer to a PermissionRequest hook.
+type PermissionRequestDecision struct {
+	// Behavior is "allow" or "deny".
+	Behavior string `json:"behavior"`
+
+	// UpdatedInput optionally replaces the tool input (allow only).
+	UpdatedInput json.RawMessage `json:"updatedInput,omitempty"`
+
+	// Message explains the denial to Claude (deny only).
+	Message string `json:"message,omitempty"`
+
+	// Interrupt stops the agent (deny only).
+	Interrupt bool `json:"interrupt,omitempty"`
+}
+
+// encodeHookSpecificOutput converts o to its wire form, tagged with the event name.
+func encodeHookSpecificOutput(o HookSpecificOutput) map[string]any {
+	if o == nil {
+		return nil
+	}
+	fields := map[string]any{}
+	if data, err := json.Marshal(o); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	fields["hookEventName"] = string(o.hookEvent())
+	return fields
 }
 
 // ContinueHook returns a HookOutput that allows execution to continue.
@@ -139,11 +449,65 @@ func BlockHook(reason string) *HookOutput {
 	}
 }
 
-// ModifyHook returns a HookOutput that modifies the tool input.
+// ModifyHook returns a PreToolUse HookOutput that modifies the tool input.
 func ModifyHook(input json.RawMessage) *HookOutput {
+	return &HookOutput{
+		Continue:       true,
+		ModifiedInput:  input,
+		SpecificOutput: PreToolUseOutput{UpdatedInput: input},
+	}
+}
+
+// AllowToolHook returns a PreToolUse HookOutput that approves the tool call
+// without asking the user.
+func AllowToolHook(reason string) *HookOutput {
+	return toolDecisionHook(PermissionDecisionAllow, reason)
+}
+
+// DenyToolHook returns a PreToolUse HookOutput that prevents the tool call.
+// The reason is shown to Claude so it can adjust.
+func DenyToolHook(reason string) *HookOutput {
+	return toolDecisionHook(PermissionDecisionDeny, reason)
+}
+
+// AskToolHook returns a PreToolUse HookOutput that asks the user to confirm
+// the tool call.
+func AskToolHook(reason string) *HookOutput {
+	return toolDecisionHook(PermissionDecisionAsk, reason)
+}
+
+// toolDecisionHook returns a PreToolUse HookOutput with a permission decision.
+func toolDecisionHook(decision PermissionDecision, reason string) *HookOutput {
+	return &HookOutput{
+		Continue: true,
+		SpecificOutput: PreToolUseOutput{
+			PermissionDecision:       decision,
+			PermissionDecisionReason: reason,
+		},
+	}
+}
+
+// PromptContextHook returns a UserPromptSubmit HookOutput that adds context to the prompt.
+func PromptContextHook(context string) *HookOutput {
+	return &HookOutput{
+		Continue:       true,
+		SpecificOutput: UserPromptSubmitOutput{AdditionalContext: context},
+	}
+}
+
+// ToolContextHook returns a PostToolUse HookOutput that adds context to the tool result.
+func ToolContextHook(context string) *HookOutput {
+	return &HookOutput{
+		Continue:       true,
+		SpecificOutput: PostToolUseOutput{AdditionalContext: context},
+	}
+}
+
+// SystemMessageHook returns a HookOutput that continues and shows a warning to the user.
+func SystemMessageHook(message string) *HookOutput {
 	return &HookOutput{
 		Continue:      true,
-		ModifiedInput: input,
+		SystemMessage: message,
 	}
 }
 
@@ -155,6 +519,33 @@ func StopHook(reason string) *HookOutput {
 	}
 }
 
+// SessionContextHook returns a SessionStart HookOutput that adds context to the session.
+func SessionContextHook(context string) *HookOutput {
+	return &HookOutput{
+		Continue:       true,
+		SpecificOutput: SessionStartOutput{AdditionalContext: context},
+	}
+}
+
+// AllowPermissionHook returns a PermissionRequest HookOutput that grants the permission.
+func AllowPermissionHook() *HookOutput {
+	return &HookOutput{
+		Continue:       true,
+		SpecificOutput: PermissionRequestOutput{Decision: PermissionRequestDecision{Behavior: "allow"}},
+	}
+}
+
+// DenyPermissionHook returns a PermissionRequest HookOutput that denies the permission.
+func DenyPermissionHook(message string) *HookOutput {
+	return &HookOutput{
+		Continue: true,
+		SpecificOutput: PermissionRequestOutput{Decision: PermissionRequestDecision{
+			Behavior: "deny",
+			Message:  message,
+		}},
+	}
+}
+
 // MatchAll creates a HookMatcher that matches all tools.
 func MatchAll(callback HookCallback) HookMatcher {
 	return HookMatcher{
@@ -171,6 +562,14 @@ func MatchTool(toolName string, callback HookCallback) HookMatcher {
 	}
 }
 
+// MatchToolCallbacks creates a HookMatcher that runs several callbacks for a tool.
+func MatchToolCallbacks(toolName string, callbacks ...HookCallback) HookMatcher {
+	return HookMatcher{
+		ToolName:  toolName,
+		Callbacks: callbacks,
+	}
+}
+
 // MatchToolWithTimeout creates a HookMatcher with a timeout.
 func MatchToolWithTimeout(toolName string, timeout time.Duration, callback HookCallback) HookMatcher {
 	return HookMatcher{
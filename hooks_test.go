@@ -0,0 +1,117 @@
+package clawde
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCompileToolPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"", []string{"Bash", ""}, nil},
+		{"*", []string{"Bash"}, nil},
+		{"Bash", []string{"Bash", ""}, []string{"BashOutput"}},
+		{"Write|Edit|MultiEdit", []string{"Edit", "MultiEdit"}, []string{"Read", "Edit|Write"}},
+		{"Notebook.*", []string{"NotebookEdit"}, []string{"Read"}},
+		{"mcp__github__.*", []string{"mcp__github__create_issue"}, []string{"mcp__slack__post"}},
+		// JavaScript-only syntax is left to the CLI.
+		{"^(?!mcp__)", []string{"Bash", "mcp__github__create_issue"}, nil},
+		{"(?<=mcp__)github", []string{"Bash"}, nil},
+		{`^(a)\1`, []string{"Bash"}, nil},
+	}
+	for _, tt := range tests {
+		p, err := compileToolPattern(tt.pattern)
+		if err != nil {
+			t.Errorf("compileToolPattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		for _, name := range tt.match {
+			if !p.match(name) {
+				t.Errorf("%q does not match %q", tt.pattern, name)
+			}
+		}
+		for _, name := range tt.noMatch {
+			if p.match(name) {
+				t.Errorf("%q matches %q", tt.pattern, name)
+			}
+		}
+	}
+
+	for _, pattern := range []string{"mcp__(", "Bash[", "a{2,1}"} {
+		if _, err := compileToolPattern(pattern); err == nil {
+			t.Errorf("compileToolPattern accepted the invalid regular expression %q", pattern)
+		}
+	}
+}
+
+func TestConnectRejectsInvalidHookMatcher(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewClient(
+		WithCLIPath(fakeCLI(t)),
+		WithHook(HookPreToolUse, MatchTool("mcp__(", func(ctx context.Context, in *HookInput) (*HookOutput, error) {
+			return ContinueHook(), nil
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(ctx); err == nil {
+		client.Close()
+		t.Fatal("Connect succeeded with invalid matcher")
+	}
+}
+
+func TestHookInputDecoding(t *testing.T) {
+	raw := `{
+		"hook_event_name": "PostToolUse",
+		"session_id": "s1",
+		"transcript_path": "/tmp/t.jsonl",
+		"cwd": "/work",
+		"permission_mode": "acceptEdits",
+		"tool_name": "Bash",
+		"tool_use_id": "tu_1",
+		"tool_input": {"command": "ls"},
+		"tool_response": {"stdout": "a\nb", "error": ""}
+	}`
+
+	var in HookInput
+	if err := json.Unmarshal([]byte(raw), &in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Cwd != "/work" || in.TranscriptPath != "/tmp/t.jsonl" || in.PermissionMode != PermissionAcceptEdits {
+		t.Errorf("common fields not decoded: %+v", in)
+	}
+	if in.ToolInputMap["command"] != "ls" {
+		t.Errorf("ToolInputMap = %v", in.ToolInputMap)
+	}
+
+	post, ok := in.PostToolUse()
+	if !ok {
+		t.Fatal("PostToolUse() not ok")
+	}
+	if post.ToolResponse["stdout"] != "a\nb" {
+		t.Errorf("ToolResponse = %v", post.ToolResponse)
+	}
+	if _, ok := in.PreToolUse(); ok {
+		t.Error("PreToolUse() ok for PostToolUse event")
+	}
+
+	var compact HookInput
+	if err := json.Unmarshal([]byte(`{"hook_event_name":"PreCompact","trigger":"manual","custom_instructions":"keep tests","tool_response":"plain"}`), &compact); err != nil {
+		t.Fatal(err)
+	}
+	pc, ok := compact.PreCompact()
+	if !ok || pc.Trigger != "manual" || pc.CustomInstructions != "keep tests" {
+		t.Errorf("PreCompact() = %+v, %v", pc, ok)
+	}
+	if compact.ToolResult["content"] != "plain" {
+		t.Errorf("non-object ToolResult = %v", compact.ToolResult)
+	}
+}
@@ -0,0 +1,295 @@
+// Command fakeclaude mimics the stream-json behaviour of the claude CLI for
+// subprocess-level tests of the SDK.
+//
+// Without a scenario it answers the initialize control request and echoes
+// every prompt back as an assistant message followed by a result. A scenario
+// file, named by the FAKECLAUDE_SCENARIO environment variable, replaces that
+// behaviour with a list of steps:
+//
+//	{
+//	  "start_delay_ms": 200,
+//	  "steps": [
+//	    {"action": "initialize"},
+//	    {"action": "args"},
+//	    {"action": "reply", "text": "hello"},
+//	    {"action": "stdout", "line": "{not json"},
+//	    {"action": "huge", "size": 1048576},
+//	    {"action": "stderr", "line": "warning"},
+//	    {"action": "sleep", "ms": 100},
+//	    {"action": "exit", "code": 3}
+//	  ]
+//	}
+//
+// Actions:
+//
+//	initialize  read stdin until the initialize control request and answer it
+//	prompt      read stdin until a user message arrives
+//	reply       prompt, then emit an assistant message with text and a result
+//	args        emit a system message with subtype "args" listing the CLI arguments
+//	stdout      write line (verbatim) or json to stdout
+//	stderr      write line to stderr
+//	huge        emit an assistant message whose text is size bytes long
+//	sleep       pause for ms milliseconds
+//	exit        exit with code
+//	crash       panic, like an unexpected runtime failure
+//
+// Control requests from the SDK that arrive while reading stdin are answered
+// with an empty success response. When the steps run out, fakeclaude waits
+// for stdin to close and exits with status 0.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const sessionID = "fake-session"
+
+// scenario describes how fakeclaude behaves.
+type scenario struct {
+	StartDelayMS int    `json:"start_delay_ms"`
+	Steps        []step `json:"steps"`
+}
+
+// step is a single scripted action.
+type step struct {
+	Action string          `json:"action"`
+	Line   string          `json:"line,omitempty"`
+	JSON   json.RawMessage `json:"json,omitempty"`
+	Text   string          `json:"text,omitempty"`
+	Size   int             `json:"size,omitempty"`
+	MS     int             `json:"ms,omitempty"`
+	Code   int             `json:"code,omitempty"`
+}
+
+// cli holds the process streams.
+type cli struct {
+	in  *bufio.Reader
+	out *bufio.Writer
+}
+
+func main() {
+	sc, err := loadScenario(os.Getenv("FAKECLAUDE_SCENARIO"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fakeclaude: %v\n", err)
+		os.Exit(1)
+	}
+
+	time.Sleep(time.Duration(sc.StartDelayMS) * time.Millisecond)
+
+	c := &cli{
+		in:  bufio.NewReaderSize(os.Stdin, 64*1024),
+		out: bufio.NewWriter(os.Stdout),
+	}
+
+	if sc.Steps == nil {
+		c.echo()
+		return
+	}
+
+	for _, s := range sc.Steps {
+		if err := c.run(s); err != nil {
+			if err == io.EOF {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "fakeclaude: %s: %v\n", s.Action, err)
+			os.Exit(1)
+		}
+	}
+
+	// Stay alive until the SDK closes stdin.
+	io.Copy(io.Discard, c.in)
+}
+
+// loadScenario reads the scenario at path, or returns the default when path is empty.
+func loadScenario(path string) (*scenario, error) {
+	if path == "" {
+		return &scenario{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	if sc.Steps == nil {
+		sc.Steps = []step{}
+	}
+	return &sc, nil
+}
+
+// echo implements the default behaviour: answer initialize and echo prompts.
+func (c *cli) echo() {
+	if err := c.run(step{Action: "initialize"}); err != nil {
+		return
+	}
+	for {
+		prompt, err := c.readPrompt()
+		if err != nil {
+			return
+		}
+		c.assistant("echo: " + prompt)
+		c.result("echo: " + prompt)
+	}
+}
+
+// run executes a single step.
+func (c *cli) run(s step) error {
+	switch s.Action {
+	case "initialize":
+		return c.readUntil(func(msg map[string]any) bool {
+			req, _ := msg["request"].(map[string]any)
+			return msg["type"] == "control_request" && req["subtype"] == "initialize"
+		})
+
+	case "prompt":
+		_, err := c.readPrompt()
+		return err
+
+	case "reply":
+		if _, err := c.readPrompt(); err != nil {
+			return err
+		}
+		c.assistant(s.Text)
+		c.result(s.Text)
+
+	case "args":
+		c.emit(map[string]any{
+			"type":       "system",
+			"subtype":    "args",
+			"session_id": sessionID,
+			"args":       os.Args[1:],
+		})
+
+	case "stdout":
+		if s.JSON != nil {
+			c.writeLine(string(s.JSON))
+		} else {
+			c.writeLine(s.Line)
+		}
+
+	case "stderr":
+		fmt.Fprintln(os.Stderr, s.Line)
+
+	case "huge":
+		c.assistant(strings.Repeat("x", s.Size))
+
+	case "sleep":
+		c.out.Flush()
+		time.Sleep(time.Duration(s.MS) * time.Millisecond)
+
+	case "exit":
+		c.out.Flush()
+		os.Exit(s.Code)
+
+	case "crash":
+		c.out.Flush()
+		panic("fakeclaude: simulated crash")
+
+	default:
+		return fmt.Errorf("unknown action")
+	}
+	return nil
+}
+
+// readPrompt reads stdin until a user message arrives and returns its text.
+func (c *cli) readPrompt() (string, error) {
+	var prompt string
+	err := c.readUntil(func(msg map[string]any) bool {
+		if msg["type"] != "user" {
+			return false
+		}
+		m, _ := msg["message"].(map[string]any)
+		switch content := m["content"].(type) {
+		case string:
+			prompt = content
+		case []any:
+			for _, b := range content {
+				if block, ok := b.(map[string]any); ok && block["type"] == "text" {
+					text, _ := block["text"].(string)
+					prompt += text
+				}
+			}
+		}
+		return true
+	})
+	return prompt, err
+}
+
+// readUntil reads stdin lines, answering control requests, until done returns true.
+// The control request that satisfies done is answered too.
+func (c *cli) readUntil(done func(msg map[string]any) bool) error {
+	for {
+		line, err := c.in.ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+
+		var msg map[string]any
+		if json.Unmarshal([]byte(line), &msg) != nil {
+			fmt.Fprintf(os.Stderr, "fakeclaude: invalid input: %s", line)
+			continue
+		}
+
+		if msg["type"] == "control_request" {
+			c.emit(map[string]any{
+				"type": "control_response",
+				"response": map[string]any{
+					"subtype":    "success",
+					"request_id": msg["request_id"],
+					"response":   map[string]any{},
+				},
+			})
+		}
+
+		if done(msg) {
+			return nil
+		}
+	}
+}
+
+// assistant emits an assistant text message.
+func (c *cli) assistant(text string) {
+	c.emit(map[string]any{
+		"type": "assistant",
+		"message": map[string]any{
+			"role":    "assistant",
+			"model":   "fake-model",
+			"content": []any{map[string]any{"type": "text", "text": text}},
+		},
+		"parent_tool_use_id": nil,
+		"session_id":         sessionID,
+	})
+}
+
+// result emits a successful result message.
+func (c *cli) result(text string) {
+	c.emit(map[string]any{
+		"type":       "result",
+		"subtype":    "success",
+		"is_error":   false,
+		"num_turns":  1,
+		"result":     text,
+		"session_id": sessionID,
+	})
+}
+
+// emit writes v as a JSON line.
+func (c *cli) emit(v any) {
+	data, _ := json.Marshal(v)
+	c.writeLine(string(data))
+}
+
+// writeLine writes a line to stdout and flushes it.
+func (c *cli) writeLine(line string) {
+	c.out.WriteString(line)
+	c.out.WriteByte('\n')
+	c.out.Flush()
+}
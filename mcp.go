@@ -3,9 +3,10 @@ package clawde
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
 	"strings"
+	"sync"
 )
 
 // MCPServerConfig configures an external MCP server.
@@ -39,8 +40,17 @@ type MCPServer struct {
 type MCPTool struct {
 	Name        string
 	Description string
+
+	// InputSchema is the JSON Schema tool call arguments are checked
+	// against. It is compiled once, by Tool, AddTool or Connect, so
+	// changes made after that have no effect.
 	InputSchema json.RawMessage
 	Handler     ToolHandler
+
+	err        error // error generating InputSchema, returned by Connect
+	schemaOnce sync.Once
+	schema     *compiledSchema // compiled InputSchema, nil if it is empty
+	schemaErr  error           // error compiling InputSchema, returned by Connect
 }
 
 // ToolHandler handles tool invocations.
@@ -65,26 +75,46 @@ func NewMCPServer(name string) *MCPServer {
 	return &MCPServer{Name: name}
 }
 
-// AddTool adds a tool to the MCP server.
+// AddTool adds a tool to the MCP server. The input schema is generated
+// from the type of schema as described for Tool.
 func (s *MCPServer) AddTool(name, description string, schema any, handler ToolHandler) {
-	schemaJSON, _ := json.Marshal(generateSchema(schema))
-	s.Tools = append(s.Tools, &MCPTool{
+	schemaJSON, err := toolInputSchema(name, schema)
+	tool := &MCPTool{
 		Name:        name,
 		Description: description,
 		InputSchema: schemaJSON,
 		Handler:     handler,
-	})
+		err:         err,
+	}
+	tool.inputSchema()
+	s.Tools = append(s.Tools, tool)
+}
+
+// toolInputSchema returns the input schema generated from the type of v.
+func toolInputSchema(name string, v any) (json.RawMessage, error) {
+	data, err := json.Marshal(generateSchema(v))
+	if err != nil {
+		return nil, fmt.Errorf("clawde: input schema of tool %s: %w", name, err)
+	}
+	return data, nil
 }
 
 // Tool creates a typed tool with automatic schema generation.
+//
+// The input schema follows encoding/json: fields are required unless
+// tagged omitempty or omitzero, and pointer fields accept null. Struct
+// schemas set additionalProperties to false, so a call with a property T
+// does not declare is rejected before the handler runs, as are calls
+// missing a required field. Embed or add fields to accept more input.
 func Tool[T any](name, description string, handler func(ctx context.Context, input T) (string, error)) *MCPTool {
 	var zero T
-	schemaJSON, _ := json.Marshal(generateSchema(zero))
+	schemaJSON, err := toolInputSchema(name, zero)
 
-	return &MCPTool{
+	tool := &MCPTool{
 		Name:        name,
 		Description: description,
 		InputSchema: schemaJSON,
+		err:         err,
 		Handler: func(ctx context.Context, raw json.RawMessage) (*ToolResult, error) {
 			var input T
 			if err := json.Unmarshal(raw, &input); err != nil {
@@ -97,6 +127,8 @@ func Tool[T any](name, description string, handler func(ctx context.Context, inp
 			return TextResult(result), nil
 		},
 	}
+	tool.inputSchema()
+	return tool
 }
 
 // TextResult creates a text tool result.
@@ -125,106 +157,6 @@ func ImageResult(data []byte, mimeType string) *ToolResult {
 	}
 }
 
-// generateSchema generates a JSON schema from a Go type.
-func generateSchema(v any) map[string]any {
-	t := reflect.TypeOf(v)
-	if t == nil {
-		return map[string]any{"type": "object"}
-	}
-
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
-	}
-
-	schema := map[string]any{
-		"type": "object",
-	}
-
-	if t.Kind() == reflect.Struct {
-		properties := make(map[string]any)
-		required := make([]string, 0)
-
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			jsonTag := field.Tag.Get("json")
-			if jsonTag == "-" {
-				continue
-			}
-
-			name := field.Name
-			isRequired := true
-
-			if jsonTag != "" {
-				parts := splitTag(jsonTag)
-				if parts[0] != "" {
-					name = parts[0]
-				}
-				for _, part := range parts[1:] {
-					if part == "omitempty" {
-						isRequired = false
-					}
-				}
-			}
-
-			propSchema := typeToSchema(field.Type)
-
-			// Add description from tag
-			if desc := field.Tag.Get("description"); desc != "" {
-				propSchema["description"] = desc
-			}
-
-			properties[name] = propSchema
-
-			if isRequired {
-				required = append(required, name)
-			}
-		}
-
-		schema["properties"] = properties
-		if len(required) > 0 {
-			schema["required"] = required
-		}
-	}
-
-	return schema
-}
-
-// typeToSchema converts a Go type to a JSON schema type.
-func typeToSchema(t reflect.Type) map[string]any {
-	switch t.Kind() {
-	case reflect.String:
-		return map[string]any{"type": "string"}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return map[string]any{"type": "integer"}
-	case reflect.Float32, reflect.Float64:
-		return map[string]any{"type": "number"}
-	case reflect.Bool:
-		return map[string]any{"type": "boolean"}
-	case reflect.Slice:
-		return map[string]any{
-			"type":  "array",
-			"items": typeToSchema(t.Elem()),
-		}
-	case reflect.Map:
-		return map[string]any{
-			"type":                 "object",
-			"additionalProperties": typeToSchema(t.Elem()),
-		}
-	case reflect.Ptr:
-		return typeToSchema(t.Elem())
-	case reflect.Struct:
-		return generateSchema(reflect.New(t).Elem().Interface())
-	default:
-		return map[string]any{"type": "string"}
-	}
-}
-
-// splitTag splits a struct tag value.
-func splitTag(tag string) []string {
-	return strings.Split(tag, ",")
-}
-
 // HandleMCPRequest handles an MCP request for SDK servers.
 func (s *MCPServer) HandleMCPRequest(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
 	switch method {
@@ -250,8 +182,15 @@ func (s *MCPServer) HandleMCPRequest(ctx context.Context, method string, params
 			return nil, fmt.Errorf("invalid request: %w", err)
 		}
 
+		if len(req.Arguments) == 0 || string(req.Arguments) == "null" {
+			req.Arguments = json.RawMessage("{}")
+		}
+
 		for _, tool := range s.Tools {
 			if tool.Name == req.Name {
+				if err := tool.validateArguments(req.Arguments); err != nil {
+					return json.Marshal(invalidArgumentsResult(tool.Name, err))
+				}
 				result, err := tool.Handler(ctx, req.Arguments)
 				if err != nil {
 					return json.Marshal(map[string]any{
@@ -271,3 +210,50 @@ func (s *MCPServer) HandleMCPRequest(ctx context.Context, method string, params
 		return nil, fmt.Errorf("unknown method: %s", method)
 	}
 }
+
+// inputSchema returns the compiled input schema, compiling it on first use.
+func (t *MCPTool) inputSchema() (*compiledSchema, error) {
+	t.schemaOnce.Do(func() {
+		if len(t.InputSchema) == 0 {
+			return
+		}
+		t.schema, t.schemaErr = decodeSchema(t.InputSchema)
+		if t.schemaErr != nil {
+			t.schemaErr = fmt.Errorf("clawde: invalid input schema of tool %s: %w", t.Name, t.schemaErr)
+		}
+	})
+	return t.schema, t.schemaErr
+}
+
+// validateArguments checks tool call arguments against the tool's input schema.
+func (t *MCPTool) validateArguments(args json.RawMessage) error {
+	cs, err := t.inputSchema()
+	if err != nil || cs == nil {
+		return err
+	}
+	return cs.validate(args)
+}
+
+// invalidArgumentsResult is the error result of a tool call whose arguments
+// do not match the input schema. It lists every violation so the model can
+// correct its call.
+func invalidArgumentsResult(toolName string, err error) map[string]any {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Invalid arguments for tool %s:", toolName)
+	for _, v := range verr.Violations {
+		fmt.Fprintf(&b, "\n- %s", v)
+	}
+	return map[string]any{
+		"content":           []map[string]any{{"type": "text", "text": b.String()}},
+		"structuredContent": map[string]any{"violations": verr.Violations},
+		"isError":           true,
+	}
+}
@@ -0,0 +1,129 @@
+package clawde
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToolArgumentValidation(t *testing.T) {
+	type forecastInput struct {
+		City  string   `json:"city" jsonschema:"minLength=1"`
+		Unit  string   `json:"unit" jsonschema:"enum=celsius|fahrenheit"`
+		Days  int      `json:"days,omitempty" jsonschema:"min=1,max=14"`
+		Dates []string `json:"dates,omitempty" jsonschema:"uniqueItems"`
+		Since string   `json:"since,omitempty" jsonschema:"format=date-time"`
+	}
+
+	var calls int
+	server := NewMCPServer("weather")
+	server.Tools = append(server.Tools, Tool("forecast", "Get a forecast", func(ctx context.Context, in forecastInput) (string, error) {
+		calls++
+		return "sunny in " + in.City, nil
+	}))
+
+	call := func(args string) (result struct {
+		Content           []ToolContent `json:"content"`
+		StructuredContent struct {
+			Violations []SchemaViolation `json:"violations"`
+		} `json:"structuredContent"`
+		IsError bool `json:"isError"`
+	}) {
+		t.Helper()
+		params := `{"name":"forecast","arguments":` + args + `}`
+		data, err := server.HandleMCPRequest(context.Background(), "tools/call", json.RawMessage(params))
+		if err != nil {
+			t.Fatalf("tools/call %s: %v", args, err)
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	ok := call(`{"city":"Oslo","unit":"celsius","days":3,"since":"2026-10-16T08:00:00Z"}`)
+	if ok.IsError || calls != 1 || ok.Content[0].Text != "sunny in Oslo" {
+		t.Errorf("valid call = %+v, handler calls = %d", ok, calls)
+	}
+
+	bad := call(`{"city":"","unit":"kelvin","days":30,"dates":["a","a"],"since":"yesterday","country":"NO"}`)
+	if !bad.IsError || calls != 1 {
+		t.Fatalf("invalid call = %+v, handler calls = %d", bad, calls)
+	}
+	want := []SchemaViolation{
+		{Path: "$.city", Message: "length must be at least 1"},
+		{Path: "$.country", Message: "unexpected property"},
+		{Path: "$.dates", Message: `items must be unique, "a" is repeated`},
+		{Path: "$.days", Message: "value must be at most 14"},
+		{Path: "$.since", Message: "value must be a valid date-time"},
+		{Path: "$.unit", Message: `value must be one of ["celsius","fahrenheit"]`},
+	}
+	if got := bad.StructuredContent.Violations; !reflect.DeepEqual(got, want) {
+		t.Errorf("violations =\n%v\nwant\n%v", got, want)
+	}
+	wantText := "Invalid arguments for tool forecast:\n" +
+		"- $.city: length must be at least 1\n" +
+		"- $.country: unexpected property\n" +
+		"- $.dates: items must be unique, \"a\" is repeated\n" +
+		"- $.days: value must be at most 14\n" +
+		"- $.since: value must be a valid date-time\n" +
+		"- $.unit: value must be one of [\"celsius\",\"fahrenheit\"]"
+	if bad.Content[0].Text != wantText {
+		t.Errorf("text =\n%s\nwant\n%s", bad.Content[0].Text, wantText)
+	}
+
+	missing := call(`null`)
+	if got := missing.StructuredContent.Violations; len(got) != 2 || got[0].Message != `missing required property "city"` {
+		t.Errorf("violations without arguments = %v", got)
+	}
+}
+
+func TestHandWrittenToolSchema(t *testing.T) {
+	var calls int
+	server := NewMCPServer("math")
+	server.Tools = append(server.Tools, &MCPTool{
+		Name:        "even",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"n":{"type":"integer","multipleOf":2}},"if":{"required":["n"]},"then":{}}`),
+		Handler: func(ctx context.Context, input json.RawMessage) (*ToolResult, error) {
+			calls++
+			return TextResult("ok"), nil
+		},
+	})
+	if err := applyOptions([]Option{WithSDKServer("math", server)}).validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	for _, tt := range []struct {
+		args    string
+		isError bool
+	}{
+		{`{"n":4}`, false},
+		{`{"n":3}`, true},
+	} {
+		data, err := server.HandleMCPRequest(context.Background(), "tools/call", json.RawMessage(`{"name":"even","arguments":`+tt.args+`}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result struct {
+			IsError bool `json:"isError"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.IsError != tt.isError {
+			t.Errorf("call with %s: %s", tt.args, data)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+
+	bad := NewMCPServer("bad")
+	bad.Tools = append(bad.Tools, &MCPTool{Name: "broken", InputSchema: json.RawMessage(`{"pattern":"("}`)})
+	err := applyOptions([]Option{WithSDKServer("bad", bad)}).validate()
+	if err == nil || !strings.HasPrefix(err.Error(), "clawde: invalid input schema of tool broken: #: bad pattern") {
+		t.Errorf("validate with a bad tool schema = %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package clawde
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"time"
 )
 
@@ -158,9 +160,21 @@ type Options struct {
 	// Env sets additional environment variables.
 	Env map[string]string
 
-	// Timeout is the maximum duration for a query.
+	// Timeout is the maximum duration of each query turn, counted from when
+	// the CLI starts answering it. When it expires the SDK interrupts the
+	// turn, waits TimeoutGracePeriod for a result, then reports ErrTimeout.
+	// If no result came, the turns queued behind it fail with ErrTimeout and
+	// new prompts are refused until the CLI ends the turn. Zero means no limit.
 	Timeout time.Duration
 
+	// TimeoutGracePeriod is how long to wait for a result after interrupting
+	// a timed-out turn. Zero means 5 seconds.
+	TimeoutGracePeriod time.Duration
+
+	// ConnectTimeout bounds how long Connect waits for the CLI to answer the
+	// initialize request. Zero means 30 seconds.
+	ConnectTimeout time.Duration
+
 	// MaxThinkingTokens enables extended thinking with the specified token budget.
 	// Minimum is 1024 tokens. Set to 0 to disable.
 	MaxThinkingTokens int
@@ -168,14 +182,43 @@ type Options struct {
 	// ResumeConversation continues an existing conversation.
 	ResumeConversation string
 
+	// SessionID is the ID of a new session (a UUID). When neither SessionID
+	// nor ResumeConversation is set and ExtraArgs pick no session, each
+	// Connect starts a new session under a generated ID, which
+	// Client.SessionID returns. SessionID itself is left empty.
+	SessionID string
+
 	// StderrCallback receives stderr output from the CLI.
 	StderrCallback StderrCallback
 
+	// OutputSchema is a JSON Schema the final result must match. The
+	// matching value is returned in ResultMessage.StructuredOutput.
+	OutputSchema json.RawMessage
+
 	// IncludePartialMessages enables streaming of partial messages.
 	IncludePartialMessages bool
 
 	// ExtraArgs allows passing arbitrary CLI arguments.
 	ExtraArgs map[string]string
+
+	// Transport replaces the default subprocess transport.
+	// A Transport can only be connected once.
+	Transport Transport
+
+	// TransportFactory creates the transport on Connect.
+	// It takes precedence over Transport.
+	TransportFactory TransportFactory
+
+	// Recorder receives a cassette of all transport traffic.
+	Recorder io.Writer
+
+	// MaxConcurrentCallbacks limits how many control requests (permission
+	// prompts, hooks and SDK MCP tool calls) are handled at once.
+	// Defaults to 8.
+	MaxConcurrentCallbacks int
+
+	// err is the first error from applying an option, returned by Connect.
+	err error
 }
 
 // Option is a functional option for configuring Options.
@@ -300,13 +343,28 @@ func WithEnv(env map[string]string) Option {
 	}
 }
 
-// WithTimeout sets the maximum duration for a query.
+// WithTimeout sets the maximum duration of each query turn.
 func WithTimeout(d time.Duration) Option {
 	return func(o *Options) {
 		o.Timeout = d
 	}
 }
 
+// WithTimeoutGracePeriod sets how long to wait for a result after
+// interrupting a timed-out turn before closing the client.
+func WithTimeoutGracePeriod(d time.Duration) Option {
+	return func(o *Options) {
+		o.TimeoutGracePeriod = d
+	}
+}
+
+// WithConnectTimeout sets how long Connect waits for the CLI to initialize.
+func WithConnectTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ConnectTimeout = d
+	}
+}
+
 // WithMaxThinkingTokens enables extended thinking with the specified token budget.
 // Minimum is 1024 tokens. Set to 0 to disable extended thinking.
 func WithMaxThinkingTokens(tokens int) Option {
@@ -322,6 +380,13 @@ func WithResumeConversation(sessionID string) Option {
 	}
 }
 
+// WithSessionID starts a new session with the given ID, which must be a UUID.
+func WithSessionID(sessionID string) Option {
+	return func(o *Options) {
+		o.SessionID = sessionID
+	}
+}
+
 // WithAgents configures custom agents.
 func WithAgents(agents map[string]AgentDefinition) Option {
 	return func(o *Options) {
@@ -416,6 +481,21 @@ func WithIncludePartialMessages(include bool) Option {
 	}
 }
 
+// WithOutputSchema requests structured output matching a JSON Schema.
+// schema is a json.RawMessage or a value that marshals to a schema,
+// such as a map[string]any.
+// An error marshaling schema is returned by Connect.
+func WithOutputSchema(schema any) Option {
+	return func(o *Options) {
+		data, err := json.Marshal(schema)
+		if err != nil {
+			o.setErr(fmt.Errorf("clawde: output schema: %w", err))
+			return
+		}
+		o.OutputSchema = data
+	}
+}
+
 // WithExtraArgs sets arbitrary CLI arguments.
 func WithExtraArgs(args map[string]string) Option {
 	return func(o *Options) {
@@ -433,7 +513,63 @@ func WithExtraArg(key, value string) Option {
 	}
 }
 
+// WithTransport sets a custom transport instead of spawning the Claude CLI.
+func WithTransport(t Transport) Option {
+	return func(o *Options) {
+		o.Transport = t
+	}
+}
+
+// WithTransportFactory sets a function that creates the transport on Connect.
+func WithTransportFactory(f TransportFactory) Option {
+	return func(o *Options) {
+		o.TransportFactory = f
+	}
+}
+
+// WithRecorder records all transport traffic as a cassette to w.
+// Cassettes can be replayed with NewReplayTransport.
+func WithRecorder(w io.Writer) Option {
+	return func(o *Options) {
+		o.Recorder = w
+	}
+}
+
+// WithMaxConcurrentCallbacks limits how many permission, hook and SDK MCP
+// callbacks run at once.
+func WithMaxConcurrentCallbacks(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrentCallbacks = n
+	}
+}
+
 // applyOptions applies functional options to create an Options struct.
+// setErr records err unless an earlier option already failed.
+func (o *Options) setErr(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// validate returns the first error from applying the options or from
+// generating the input schema of an SDK server tool.
+func (o *Options) validate() error {
+	if o.err != nil {
+		return o.err
+	}
+	for _, server := range o.SDKServers {
+		for _, tool := range server.Tools {
+			if tool.err != nil {
+				return tool.err
+			}
+			if _, err := tool.inputSchema(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func applyOptions(opts []Option) *Options {
 	o := &Options{}
 	for _, opt := range opts {
@@ -97,10 +97,13 @@ func parseAssistantMessage(data json.RawMessage) (*AssistantMessage, error) {
 		Type            string  `json:"type"`
 		ParentToolUseID *string `json:"parent_tool_use_id"`
 		Message         struct {
-			Role    string            `json:"role"`
-			Content []json.RawMessage `json:"content"`
-			Model   string            `json:"model"`
-			Error   *string           `json:"error"`
+			ID         string            `json:"id"`
+			Role       string            `json:"role"`
+			Content    []json.RawMessage `json:"content"`
+			Model      string            `json:"model"`
+			StopReason *string           `json:"stop_reason"`
+			Usage      *Usage            `json:"usage"`
+			Error      *string           `json:"error"`
 		} `json:"message"`
 	}
 
@@ -109,8 +112,14 @@ func parseAssistantMessage(data json.RawMessage) (*AssistantMessage, error) {
 	}
 
 	msg := &AssistantMessage{
-		Role:  raw.Message.Role,
-		Model: raw.Message.Model,
+		ID:              raw.Message.ID,
+		Role:            raw.Message.Role,
+		Model:           raw.Message.Model,
+		Usage:           raw.Message.Usage,
+		ParentToolUseID: raw.ParentToolUseID,
+	}
+	if raw.Message.StopReason != nil {
+		msg.StopReason = *raw.Message.StopReason
 	}
 
 	for _, block := range raw.Message.Content {
@@ -130,6 +139,14 @@ func parseSystemMessage(data json.RawMessage) (*SystemMessage, error) {
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, &ParseError{Line: string(data), Err: err}
 	}
+	msg.Data = append(json.RawMessage(nil), data...)
+	if msg.Subtype == "init" {
+		var init InitMessage
+		if err := json.Unmarshal(data, &init); err != nil {
+			return nil, &ParseError{Line: string(data), Err: err}
+		}
+		msg.Init = &init
+	}
 	return &msg, nil
 }
 
@@ -148,6 +165,17 @@ func parseStreamEvent(data json.RawMessage) (*StreamEvent, error) {
 	if err := json.Unmarshal(data, &event); err != nil {
 		return nil, &ParseError{Line: string(data), Err: err}
 	}
+
+	// The API event is wrapped in "event", or inlined by older CLIs.
+	raw := event.Event
+	if len(raw) == 0 {
+		raw = data
+	}
+	decoded, err := decodeStreamEventData(raw)
+	if err != nil {
+		return nil, &ParseError{Line: string(data), Err: err}
+	}
+	event.Data = decoded
 	return &event, nil
 }
 
@@ -230,6 +258,13 @@ func parseContentBlock(data json.RawMessage) (ContentBlock, error) {
 		}
 		return &block, nil
 
+	case "document":
+		var block DocumentBlock
+		if err := json.Unmarshal(data, &block); err != nil {
+			return nil, &ParseError{Line: string(data), Err: err}
+		}
+		return &block, nil
+
 	default:
 		// Return text block for unknown types
 		return &TextBlock{}, nil
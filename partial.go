@@ -0,0 +1,270 @@
+package clawde
+
+import (
+	"encoding/json"
+)
+
+// StreamEventData is a decoded API streaming event carried by a StreamEvent.
+// It is one of *MessageStartEvent, *ContentBlockStartEvent,
+// *ContentBlockDeltaEvent, *ContentBlockStopEvent, *MessageDeltaEvent or
+// *MessageStopEvent.
+type StreamEventData interface {
+	isStreamEventData()
+}
+
+// MessageStartEvent starts a new assistant message.
+type MessageStartEvent struct {
+	ID    string
+	Model string
+	Usage *Usage
+}
+
+// ContentBlockStartEvent starts a content block at Index.
+type ContentBlockStartEvent struct {
+	Index int
+	// Block is the initial block: *TextBlock, *ThinkingBlock or *ToolUseBlock.
+	Block ContentBlock
+}
+
+// ContentBlockDeltaEvent extends the content block at Index.
+type ContentBlockDeltaEvent struct {
+	Index int
+	Delta ContentDelta
+}
+
+// ContentBlockStopEvent ends the content block at Index.
+type ContentBlockStopEvent struct {
+	Index int
+}
+
+// MessageDeltaEvent updates top-level fields of the message.
+type MessageDeltaEvent struct {
+	StopReason string
+	Usage      *Usage
+}
+
+// MessageStopEvent ends the message.
+type MessageStopEvent struct{}
+
+func (*MessageStartEvent) isStreamEventData()      {}
+func (*ContentBlockStartEvent) isStreamEventData() {}
+func (*ContentBlockDeltaEvent) isStreamEventData() {}
+func (*ContentBlockStopEvent) isStreamEventData()  {}
+func (*MessageDeltaEvent) isStreamEventData()      {}
+func (*MessageStopEvent) isStreamEventData()       {}
+
+// Content delta types.
+const (
+	DeltaText      = "text_delta"
+	DeltaThinking  = "thinking_delta"
+	DeltaSignature = "signature_delta"
+	DeltaInputJSON = "input_json_delta"
+)
+
+// ContentDelta is an increment of a content block. Which field is set
+// depends on Type.
+type ContentDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	Thinking    string `json:"thinking,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+}
+
+// decodeStreamEventData decodes an API streaming event.
+// Unknown event types decode to nil.
+func decodeStreamEventData(data json.RawMessage) (StreamEventData, error) {
+	var raw struct {
+		Type         string          `json:"type"`
+		Index        int             `json:"index"`
+		Message      json.RawMessage `json:"message"`
+		ContentBlock json.RawMessage `json:"content_block"`
+		Delta        json.RawMessage `json:"delta"`
+		Usage        *Usage          `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	switch raw.Type {
+	case "message_start":
+		var msg struct {
+			ID    string `json:"id"`
+			Model string `json:"model"`
+			Usage *Usage `json:"usage"`
+		}
+		if len(raw.Message) > 0 {
+			if err := json.Unmarshal(raw.Message, &msg); err != nil {
+				return nil, err
+			}
+		}
+		return &MessageStartEvent{ID: msg.ID, Model: msg.Model, Usage: msg.Usage}, nil
+
+	case "content_block_start":
+		block, err := parseContentBlock(raw.ContentBlock)
+		if err != nil {
+			return nil, err
+		}
+		return &ContentBlockStartEvent{Index: raw.Index, Block: block}, nil
+
+	case "content_block_delta":
+		var delta ContentDelta
+		if err := json.Unmarshal(raw.Delta, &delta); err != nil {
+			return nil, err
+		}
+		return &ContentBlockDeltaEvent{Index: raw.Index, Delta: delta}, nil
+
+	case "content_block_stop":
+		return &ContentBlockStopEvent{Index: raw.Index}, nil
+
+	case "message_delta":
+		var delta struct {
+			StopReason string `json:"stop_reason"`
+		}
+		if len(raw.Delta) > 0 {
+			if err := json.Unmarshal(raw.Delta, &delta); err != nil {
+				return nil, err
+			}
+		}
+		return &MessageDeltaEvent{StopReason: delta.StopReason, Usage: raw.Usage}, nil
+
+	case "message_stop":
+		return &MessageStopEvent{}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// MessageAccumulator rebuilds an assistant message from partial stream
+// events so it can be rendered while it is generated.
+//
+//	acc := clawde.NewMessageAccumulator()
+//	for stream.Next() {
+//		if ev, ok := stream.Current().(*clawde.StreamEvent); ok && acc.Add(ev) {
+//			render(acc.Message())
+//		}
+//	}
+type MessageAccumulator struct {
+	msg    *AssistantMessage
+	inputs map[int][]byte // partial tool input JSON by block index
+	done   bool
+}
+
+// NewMessageAccumulator returns an empty accumulator.
+func NewMessageAccumulator() *MessageAccumulator {
+	return &MessageAccumulator{
+		msg:    &AssistantMessage{Role: "assistant"},
+		inputs: make(map[int][]byte),
+	}
+}
+
+// Add applies a stream event and reports whether the message changed.
+// A message_start event begins a new message.
+func (a *MessageAccumulator) Add(ev *StreamEvent) bool {
+	switch e := ev.Data.(type) {
+	case *MessageStartEvent:
+		a.msg = &AssistantMessage{
+			ID:              e.ID,
+			Role:            "assistant",
+			Model:           e.Model,
+			ParentToolUseID: ev.ParentToolUseID,
+		}
+		if e.Usage != nil {
+			usage := *e.Usage
+			a.msg.Usage = &usage
+		}
+		a.inputs = make(map[int][]byte)
+		a.done = false
+
+	case *ContentBlockStartEvent:
+		for len(a.msg.Content) <= e.Index {
+			a.msg.Content = append(a.msg.Content, &TextBlock{})
+		}
+		a.msg.Content[e.Index] = e.Block
+
+	case *ContentBlockDeltaEvent:
+		if e.Index >= len(a.msg.Content) {
+			return false
+		}
+		switch b := a.msg.Content[e.Index].(type) {
+		case *TextBlock:
+			b.Text += e.Delta.Text
+		case *ThinkingBlock:
+			b.Thinking += e.Delta.Thinking
+			b.Signature += e.Delta.Signature
+		case *ToolUseBlock:
+			a.inputs[e.Index] = append(a.inputs[e.Index], e.Delta.PartialJSON...)
+		default:
+			return false
+		}
+
+	case *ContentBlockStopEvent:
+		if e.Index >= len(a.msg.Content) {
+			return false
+		}
+		if b, ok := a.msg.Content[e.Index].(*ToolUseBlock); ok {
+			if input := a.inputs[e.Index]; json.Valid(input) {
+				b.Input = json.RawMessage(input)
+			}
+			if len(b.Input) == 0 {
+				b.Input = json.RawMessage("{}")
+			}
+			delete(a.inputs, e.Index)
+		}
+
+	case *MessageDeltaEvent:
+		if e.StopReason != "" {
+			a.msg.StopReason = e.StopReason
+		}
+		if e.Usage != nil {
+			a.mergeUsage(e.Usage)
+		}
+
+	case *MessageStopEvent:
+		a.done = true
+
+	default:
+		return false
+	}
+	return true
+}
+
+// mergeUsage applies the non-zero token counts of a message_delta event,
+// which only carries the counts that changed.
+func (a *MessageAccumulator) mergeUsage(u *Usage) {
+	if a.msg.Usage == nil {
+		a.msg.Usage = &Usage{}
+	}
+	if u.InputTokens != 0 {
+		a.msg.Usage.InputTokens = u.InputTokens
+	}
+	if u.OutputTokens != 0 {
+		a.msg.Usage.OutputTokens = u.OutputTokens
+	}
+	if u.CacheCreationInputTokens != 0 {
+		a.msg.Usage.CacheCreationInputTokens = u.CacheCreationInputTokens
+	}
+	if u.CacheReadInputTokens != 0 {
+		a.msg.Usage.CacheReadInputTokens = u.CacheReadInputTokens
+	}
+}
+
+// Message returns the message built so far. The Input of a tool use block
+// is set once the block has been fully streamed; until then it keeps its
+// initial value and PartialInput returns the JSON received so far.
+func (a *MessageAccumulator) Message() *AssistantMessage {
+	return a.msg
+}
+
+// Done reports whether the message has been fully streamed.
+func (a *MessageAccumulator) Done() bool {
+	return a.done
+}
+
+// PartialInput returns the tool input JSON streamed so far for the tool use
+// block at index, which is usually incomplete. It returns "" once the block
+// has been fully streamed.
+func (a *MessageAccumulator) PartialInput(index int) string {
+	return string(a.inputs[index])
+}
@@ -0,0 +1,78 @@
+package clawde
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessageAccumulator(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5","usage":{"input_tokens":10,"output_tokens":1,"cache_read_input_tokens":5}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"check."}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Reading "}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"the file."}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"Read","input":{}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"file_"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"path\":\"a.go\"}"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":42}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	acc := NewMessageAccumulator()
+	for i, event := range events {
+		line := `{"type":"stream_event","uuid":"u","session_id":"s","event":` + event + `}`
+		msg, err := ParseMessage(json.RawMessage(line))
+		if err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+		ev, ok := msg.(*StreamEvent)
+		if !ok || ev.Data == nil {
+			t.Fatalf("event %d parsed as %#v", i, msg)
+		}
+		if !acc.Add(ev) {
+			t.Errorf("event %d did not change the message", i)
+		}
+
+		// Mid-stream the tool input is the JSON received so far, and the
+		// message still marshals.
+		if i == 11 {
+			if got := acc.PartialInput(2); got != `{"file_` {
+				t.Errorf("PartialInput = %q", got)
+			}
+			if _, err := json.Marshal(acc.Message()); err != nil {
+				t.Errorf("marshal mid-stream: %v", err)
+			}
+		}
+	}
+
+	if !acc.Done() {
+		t.Error("accumulator not done after message_stop")
+	}
+	m := acc.Message()
+	if m.ID != "msg_1" || m.Model != "claude-sonnet-4-5" || m.StopReason != "tool_use" {
+		t.Errorf("message = %+v", m)
+	}
+	if m.Usage == nil || *m.Usage != (Usage{InputTokens: 10, OutputTokens: 42, CacheReadInputTokens: 5}) {
+		t.Errorf("usage = %+v", m.Usage)
+	}
+	if got := m.Thinking(); got != "Let me check." {
+		t.Errorf("thinking = %q", got)
+	}
+	if tb, ok := m.Content[0].(*ThinkingBlock); !ok || tb.Signature != "sig" {
+		t.Errorf("thinking block = %#v", m.Content[0])
+	}
+	if got := m.Text(); got != "Reading the file." {
+		t.Errorf("text = %q", got)
+	}
+	tools := m.ToolUses()
+	if len(tools) != 1 || tools[0].Name != "Read" || string(tools[0].Input) != `{"file_path":"a.go"}` {
+		t.Errorf("tool uses = %+v", tools)
+	}
+}
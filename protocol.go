@@ -50,15 +50,19 @@ type HookCallbackRequest struct {
 	CallbackID string     `json:"callback_id"`
 	Event      string     `json:"event"`
 	Input      *HookInput `json:"input"`
+	ToolUseID  string     `json:"tool_use_id,omitempty"`
 }
 
 // HookCallbackResponse contains the hook result.
+// Field names follow the Claude Code hook output schema.
 type HookCallbackResponse struct {
-	Continue      bool            `json:"continue"`
-	Decision      string          `json:"decision,omitempty"`
-	Reason        string          `json:"reason,omitempty"`
-	StopReason    string          `json:"stop_reason,omitempty"`
-	ModifiedInput json.RawMessage `json:"modified_input,omitempty"`
+	Continue           bool           `json:"continue"`
+	SuppressOutput     bool           `json:"suppressOutput,omitempty"`
+	StopReason         string         `json:"stopReason,omitempty"`
+	Decision           string         `json:"decision,omitempty"`
+	Reason             string         `json:"reason,omitempty"`
+	SystemMessage      string         `json:"systemMessage,omitempty"`
+	HookSpecificOutput map[string]any `json:"hookSpecificOutput,omitempty"`
 }
 
 // MCPMessageRequest routes a message to an MCP server.
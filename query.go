@@ -3,11 +3,18 @@ package clawde
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// defaultMaxConcurrentCallbacks bounds concurrent control request handling
+// when Options.MaxConcurrentCallbacks is not set.
+const defaultMaxConcurrentCallbacks = 8
+
 // QueryHandler handles the control protocol for a single query.
 type QueryHandler struct {
 	transport        Transport
@@ -15,24 +22,70 @@ type QueryHandler struct {
 	msgCh            chan Message
 	errCh            chan error
 	doneCh           chan struct{}
+	callbackSem      chan struct{}
 	mu               sync.Mutex
+	writeMu          sync.Mutex
 	started          bool
 	closed           bool
-	initRequestID    string
-	initResponseCh   chan json.RawMessage
-	pendingResponses map[string]chan json.RawMessage
+	stopped          bool
+	sessionID        string
+	interrupting     bool
+	serverInfo       *InitMessage
+	nextRequestID    int
+	pendingResponses map[string]*pendingControl
+	hookCallbacks    map[string]hookRegistration
+	inflight         map[string]*inflightRequest
+}
+
+// defaultConnectTimeout bounds initialization when Options.ConnectTimeout is not set.
+const defaultConnectTimeout = 30 * time.Second
+
+// defaultControlRequestTimeout bounds how long SendControlRequest waits for the CLI.
+const defaultControlRequestTimeout = 60 * time.Second
+
+// pendingControl is a control request sent to the CLI awaiting its response.
+type pendingControl struct {
+	subtype string
+	ch      chan controlResult
+}
+
+// controlResult is the outcome of a control request sent to the CLI.
+type controlResult struct {
+	response json.RawMessage
+	err      error
+}
+
+// inflightRequest is a control request from the CLI that is being handled.
+type inflightRequest struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// hookRegistration is a hook callback registered with the CLI under its own ID.
+type hookRegistration struct {
+	event    HookEvent
+	matcher  *toolPattern
+	callback HookCallback
+	timeout  time.Duration
 }
 
 // NewQueryHandler creates a new query handler.
 func NewQueryHandler(transport Transport, opts *Options) *QueryHandler {
+	maxCallbacks := opts.MaxConcurrentCallbacks
+	if maxCallbacks <= 0 {
+		maxCallbacks = defaultMaxConcurrentCallbacks
+	}
+
 	return &QueryHandler{
 		transport:        transport,
 		opts:             opts,
 		msgCh:            make(chan Message, 100),
 		errCh:            make(chan error, 10),
 		doneCh:           make(chan struct{}),
-		initResponseCh:   make(chan json.RawMessage, 1),
-		pendingResponses: make(map[string]chan json.RawMessage),
+		callbackSem:      make(chan struct{}, maxCallbacks),
+		pendingResponses: make(map[string]*pendingControl),
+		sessionID:        initialSessionID(opts),
+		inflight:         make(map[string]*inflightRequest),
 	}
 }
 
@@ -53,26 +106,56 @@ func (q *QueryHandler) Start(ctx context.Context) error {
 // Initialize sends the initialization request to the CLI.
 // This must be called before sending prompts.
 func (q *QueryHandler) Initialize(ctx context.Context) error {
-	// Build hooks configuration
+	// Build hooks configuration, registering one callback ID per callback
 	var hooksConfig map[string]any
+	q.hookCallbacks = make(map[string]hookRegistration)
 	if len(q.opts.Hooks) > 0 {
 		hooksConfig = make(map[string]any)
-		for event, matchers := range q.opts.Hooks {
+
+		// Register events in a fixed order and derive callback IDs from
+		// the event and position, so the initialize request is the same
+		// on every run and recorded cassettes replay.
+		events := make([]string, 0, len(q.opts.Hooks))
+		for event := range q.opts.Hooks {
+			events = append(events, string(event))
+		}
+		sort.Strings(events)
+		for _, name := range events {
+			event := HookEvent(name)
+			matchers := q.opts.Hooks[event]
 			if q.opts.StderrCallback != nil {
 				q.opts.StderrCallback(fmt.Sprintf("[clawde] Initialize: registering hook event=%s matchers=%d", event, len(matchers)))
 			}
 			var matcherConfigs []map[string]any
-			for _, matcher := range matchers {
+			for i, matcher := range matchers {
+				// Validate the pattern before handing it to the CLI
+				pattern, err := compileToolPattern(matcher.ToolName)
+				if err != nil {
+					return err
+				}
+
+				var callbackIDs []string
+				for j, cb := range matcher.callbacks() {
+					callbackID := fmt.Sprintf("hook_%s_%d_%d", event, i, j)
+					q.hookCallbacks[callbackID] = hookRegistration{
+						event:    event,
+						matcher:  pattern,
+						callback: cb,
+						timeout:  matcher.Timeout,
+					}
+					callbackIDs = append(callbackIDs, callbackID)
+				}
+
 				matcherConfig := map[string]any{
 					"matcher":         matcher.ToolName,
-					"hookCallbackIds": []string{string(event) + "_callback"},
+					"hookCallbackIds": callbackIDs,
 				}
 				if matcher.Timeout > 0 {
-					matcherConfig["timeout"] = matcher.Timeout.Milliseconds()
+					matcherConfig["timeout"] = matcher.Timeout.Seconds()
 				}
 				matcherConfigs = append(matcherConfigs, matcherConfig)
 				if q.opts.StderrCallback != nil {
-					q.opts.StderrCallback(fmt.Sprintf("[clawde] Initialize: hook matcher tool=%s callback_id=%s", matcher.ToolName, string(event)+"_callback"))
+					q.opts.StderrCallback(fmt.Sprintf("[clawde] Initialize: hook matcher tool=%s callback_ids=%v", matcher.ToolName, callbackIDs))
 				}
 			}
 			hooksConfig[string(event)] = matcherConfigs
@@ -115,47 +198,140 @@ func (q *QueryHandler) Initialize(ctx context.Context) error {
 		innerRequest["mcp_servers"] = mcpServersConfig
 	}
 
-	// Generate request ID
+	timeout := q.opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	resp, err := q.sendControlRequest(ctx, innerRequest, timeout)
+	if err != nil {
+		return err
+	}
+
+	info := &InitMessage{}
+	if len(resp) > 0 && string(resp) != "null" {
+		if err := json.Unmarshal(resp, info); err != nil {
+			return &ParseError{Line: string(resp), Err: err}
+		}
+	}
+	q.mu.Lock()
+	q.serverInfo = info
+	q.mu.Unlock()
+	return nil
+}
+
+// ServerInfo returns the session metadata reported by the CLI. It is filled
+// from the initialize response and updated by each "init" system message.
+func (q *QueryHandler) ServerInfo() *InitMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.serverInfo
+}
+
+// updateServerInfo records the metadata of an "init" system message,
+// keeping the commands reported at initialization.
+func (q *QueryHandler) updateServerInfo(init *InitMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	info := *init
+	if len(info.Commands) == 0 && q.serverInfo != nil {
+		info.Commands = q.serverInfo.Commands
+	}
+	if info.OutputStyle == "" && q.serverInfo != nil {
+		info.OutputStyle = q.serverInfo.OutputStyle
+	}
+	q.serverInfo = &info
+}
+
+// SendControlRequest sends a control request to the CLI and waits for its
+// response. request must contain a "subtype". An error response from the CLI
+// is returned as a *ControlError.
+func (q *QueryHandler) SendControlRequest(ctx context.Context, request map[string]any) (json.RawMessage, error) {
+	return q.sendControlRequest(ctx, request, defaultControlRequestTimeout)
+}
+
+// Interrupt asks the CLI to stop the current turn. The error result ending
+// an interrupted turn is reported as ErrInterrupted.
+func (q *QueryHandler) Interrupt(ctx context.Context) error {
+	q.mu.Lock()
+	q.interrupting = true
+	q.mu.Unlock()
+
+	if _, err := q.SendControlRequest(ctx, map[string]any{"subtype": "interrupt"}); err != nil {
+		q.mu.Lock()
+		q.interrupting = false
+		q.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// sendControlRequest sends a control request and waits up to timeout for the response.
+func (q *QueryHandler) sendControlRequest(ctx context.Context, request map[string]any, timeout time.Duration) (json.RawMessage, error) {
+	subtype, _ := request["subtype"].(string)
+
 	q.mu.Lock()
-	q.initRequestID = fmt.Sprintf("init_%d", time.Now().UnixNano())
-	requestID := q.initRequestID
+	if q.closed {
+		q.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	if q.stopped {
+		q.mu.Unlock()
+		return nil, ErrStreamClosed
+	}
+	q.nextRequestID++
+	requestID := fmt.Sprintf("req_%d_%d", q.nextRequestID, time.Now().UnixNano())
+	pending := &pendingControl{subtype: subtype, ch: make(chan controlResult, 1)}
+	q.pendingResponses[requestID] = pending
 	q.mu.Unlock()
 
-	// Send control request with correct format
-	controlRequest := map[string]any{
+	defer func() {
+		q.mu.Lock()
+		delete(q.pendingResponses, requestID)
+		q.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(map[string]any{
 		"type":       "control_request",
 		"request_id": requestID,
-		"request":    innerRequest,
+		"request":    request,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := json.Marshal(controlRequest)
-	if err != nil {
-		return err
+	if q.opts.StderrCallback != nil {
+		q.opts.StderrCallback(fmt.Sprintf("[clawde] sendControlRequest: subtype=%s request_id=%s", subtype, requestID))
 	}
 
-	if err := q.transport.Write(data); err != nil {
-		return err
+	if err := q.write(data); err != nil {
+		return nil, err
 	}
 
-	// Wait for initialization response
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
 	select {
+	case res := <-pending.ch:
+		return res.response, res.err
 	case <-ctx.Done():
-		return ctx.Err()
-	case <-q.initResponseCh:
-		return nil
-	case <-time.After(30 * time.Second):
-		return &ProtocolError{Message: "initialization timeout"}
+		return nil, ctx.Err()
+	case <-q.doneCh:
+		return nil, ErrNotConnected
+	case <-timer.C:
+		return nil, fmt.Errorf("%w waiting for %s response", ErrTimeout, subtype)
 	}
 }
 
 // processLoop reads messages and handles control requests.
 func (q *QueryHandler) processLoop(ctx context.Context) {
 	defer close(q.msgCh)
+	defer q.failPendingRequests()
 
 	for {
 		select {
 		case <-ctx.Done():
-			q.errCh <- ctx.Err()
+			q.reportError(ctx.Err())
 			return
 
 		case <-q.doneCh:
@@ -163,6 +339,7 @@ func (q *QueryHandler) processLoop(ctx context.Context) {
 
 		case raw, ok := <-q.transport.Messages():
 			if !ok {
+				q.drainTransportErrors()
 				return
 			}
 
@@ -171,7 +348,7 @@ func (q *QueryHandler) processLoop(ctx context.Context) {
 				Type string `json:"type"`
 			}
 			if err := json.Unmarshal(raw, &envelope); err != nil {
-				q.errCh <- &ParseError{Line: string(raw), Err: err}
+				q.reportError(&ParseError{Line: string(raw), Err: err})
 				continue
 			}
 
@@ -180,7 +357,7 @@ func (q *QueryHandler) processLoop(ctx context.Context) {
 			}
 
 			if envelope.Type == "control_request" {
-				q.handleControlRequest(ctx, raw)
+				q.dispatchControlRequest(ctx, raw)
 				continue
 			}
 
@@ -192,18 +369,31 @@ func (q *QueryHandler) processLoop(ctx context.Context) {
 
 			// Handle control cancel request (CLI cancelling a pending callback)
 			if envelope.Type == "control_cancel_request" {
-				if q.opts.StderrCallback != nil {
-					q.opts.StderrCallback("[clawde] processLoop: ignoring control_cancel_request (no pending callbacks)")
-				}
+				q.handleControlCancel(raw)
 				continue
 			}
 
 			// Parse as regular message
 			msg, err := ParseMessage(raw)
 			if err != nil {
-				q.errCh <- err
+				q.reportError(err)
 				continue
 			}
+			switch m := msg.(type) {
+			case *SystemMessage:
+				if m.Init != nil {
+					q.updateServerInfo(m.Init)
+				}
+				q.setSessionID(m.SessionID)
+			case *ResultMessage:
+				q.setSessionID(m.SessionID)
+				q.mu.Lock()
+				if q.interrupting && (m.IsError || strings.HasPrefix(m.Subtype, "error")) {
+					m.interrupted = true
+				}
+				q.interrupting = false
+				q.mu.Unlock()
+			}
 
 			select {
 			case q.msgCh <- msg:
@@ -215,20 +405,168 @@ func (q *QueryHandler) processLoop(ctx context.Context) {
 
 		case err, ok := <-q.transport.Errors():
 			if ok && err != nil {
-				q.errCh <- err
+				q.reportError(err)
+			}
+		}
+	}
+}
+
+// failPendingRequests fails control requests still awaiting a response once
+// no more responses can arrive.
+func (q *QueryHandler) failPendingRequests() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = true
+	for requestID, pending := range q.pendingResponses {
+		pending.ch <- controlResult{err: ErrStreamClosed}
+		delete(q.pendingResponses, requestID)
+	}
+}
+
+// drainTransportErrors forwards errors the transport reported before it
+// closed its message channel, such as the subprocess exit status.
+func (q *QueryHandler) drainTransportErrors() {
+	for {
+		select {
+		case err, ok := <-q.transport.Errors():
+			if !ok {
+				return
+			}
+			if err != nil {
+				select {
+				case q.errCh <- err:
+				default:
+				}
 			}
+		default:
+			return
 		}
 	}
 }
 
+// dispatchControlRequest handles a control request on its own goroutine so
+// that slow callbacks do not hold up other messages. At most
+// Options.MaxConcurrentCallbacks requests are handled at once. Each request
+// runs under its own context, cancelled by a matching control_cancel_request.
+func (q *QueryHandler) dispatchControlRequest(ctx context.Context, raw json.RawMessage) {
+	var envelope struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		q.reportError(&ParseError{Line: string(raw), Err: err})
+		return
+	}
+	requestID := envelope.RequestID
+	if requestID == "" {
+		q.rejectControlRequest(requestID, "control request without request_id")
+		return
+	}
+
+	q.mu.Lock()
+	if _, ok := q.inflight[requestID]; ok {
+		q.mu.Unlock()
+		q.rejectControlRequest(requestID, fmt.Sprintf("control request %s is already in flight", requestID))
+		return
+	}
+	reqCtx, cancel := context.WithCancel(ctx)
+	q.inflight[requestID] = &inflightRequest{cancel: cancel}
+	q.mu.Unlock()
+
+	go func() {
+		defer q.finishControlRequest(requestID)
+
+		select {
+		case q.callbackSem <- struct{}{}:
+		case <-q.doneCh:
+			return
+		case <-reqCtx.Done():
+			return
+		}
+		defer func() { <-q.callbackSem }()
+
+		q.handleControlRequest(reqCtx, requestID, raw)
+	}()
+}
+
+// rejectControlRequest answers a control request that cannot be handled
+// with an error response.
+func (q *QueryHandler) rejectControlRequest(requestID, message string) {
+	if q.opts.StderrCallback != nil {
+		q.opts.StderrCallback(fmt.Sprintf("[clawde] rejectControlRequest: request_id=%q: %s", requestID, message))
+	}
+	resp, err := json.Marshal(map[string]any{
+		"type": "control_response",
+		"response": map[string]any{
+			"subtype":    "error",
+			"request_id": requestID,
+			"error":      message,
+		},
+	})
+	if err != nil {
+		q.reportError(err)
+		return
+	}
+	if err := q.write(resp); err != nil {
+		q.reportError(err)
+	}
+}
+
+// finishControlRequest releases the context of a handled control request.
+func (q *QueryHandler) finishControlRequest(requestID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if r, ok := q.inflight[requestID]; ok {
+		r.cancel()
+		delete(q.inflight, requestID)
+	}
+}
+
+// handleControlCancel cancels the in-flight control request named by a
+// control_cancel_request. Its response will not be sent.
+func (q *QueryHandler) handleControlCancel(raw json.RawMessage) {
+	var envelope struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		q.reportError(&ParseError{Line: string(raw), Err: err})
+		return
+	}
+
+	q.mu.Lock()
+	r, ok := q.inflight[envelope.RequestID]
+	if ok {
+		r.cancelled = true
+		r.cancel()
+	}
+	q.mu.Unlock()
+
+	if q.opts.StderrCallback != nil {
+		q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlCancel: request_id=%s in_flight=%v", envelope.RequestID, ok))
+	}
+}
+
+// isCancelled reports whether the CLI cancelled the control request.
+func (q *QueryHandler) isCancelled(requestID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	r, ok := q.inflight[requestID]
+	return ok && r.cancelled
+}
+
 // handleControlRequest processes a control request and sends a response.
-func (q *QueryHandler) handleControlRequest(ctx context.Context, raw json.RawMessage) {
+// Requests that cannot be parsed or have an unknown subtype get an error
+// response, so the CLI does not wait for them.
+func (q *QueryHandler) handleControlRequest(ctx context.Context, requestID string, raw json.RawMessage) {
 	var req ControlRequest
 	if err := json.Unmarshal(raw, &req); err != nil {
 		if q.opts.StderrCallback != nil {
 			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlRequest: parse error: %v", err))
 		}
-		q.errCh <- &ParseError{Line: string(raw), Err: err}
+		q.reportError(&ParseError{Line: string(raw), Err: err})
+		q.rejectControlRequest(requestID, fmt.Sprintf("malformed control request: %v", err))
 		return
 	}
 
@@ -241,7 +579,13 @@ func (q *QueryHandler) handleControlRequest(ctx context.Context, raw json.RawMes
 		if q.opts.StderrCallback != nil {
 			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlRequest: parseControlRequest error: %v", err))
 		}
-		q.errCh <- err
+		// An unknown subtype is answered but does not fail the turn, as
+		// newer CLI versions may send requests this SDK does not know.
+		var protoErr *ProtocolError
+		if !errors.As(err, &protoErr) {
+			q.reportError(err)
+		}
+		q.rejectControlRequest(requestID, err.Error())
 		return
 	}
 
@@ -278,7 +622,15 @@ func (q *QueryHandler) handleControlRequest(ctx context.Context, raw json.RawMes
 		if q.opts.StderrCallback != nil {
 			q.opts.StderrCallback("[clawde] handleControlRequest: unknown request type")
 		}
-		q.errCh <- &ProtocolError{Message: "unknown request type"}
+		q.reportError(&ProtocolError{Message: "unknown request type"})
+		q.rejectControlRequest(requestID, "unknown request type")
+		return
+	}
+
+	if q.isCancelled(req.RequestID) {
+		if q.opts.StderrCallback != nil {
+			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlRequest: request_id=%s cancelled, dropping response", req.RequestID))
+		}
 		return
 	}
 
@@ -298,7 +650,7 @@ func (q *QueryHandler) handleControlRequest(ctx context.Context, raw json.RawMes
 		if q.opts.StderrCallback != nil {
 			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlRequest: marshal error: %v", err))
 		}
-		q.errCh <- err
+		q.reportError(err)
 		return
 	}
 
@@ -306,11 +658,11 @@ func (q *QueryHandler) handleControlRequest(ctx context.Context, raw json.RawMes
 		q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlRequest: sending response len=%d", len(respJSON)))
 	}
 
-	if err := q.transport.Write(respJSON); err != nil {
+	if err := q.write(respJSON); err != nil {
 		if q.opts.StderrCallback != nil {
 			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlRequest: write error: %v", err))
 		}
-		q.errCh <- err
+		q.reportError(err)
 	}
 }
 
@@ -328,32 +680,33 @@ func (q *QueryHandler) handleControlResponse(raw json.RawMessage) {
 		} `json:"response"`
 	}
 	if err := json.Unmarshal(raw, &envelope); err != nil {
-		q.errCh <- &ParseError{Line: string(raw), Err: err}
+		q.reportError(&ParseError{Line: string(raw), Err: err})
 		return
 	}
 
 	requestID := envelope.Response.RequestID
 
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	pending, ok := q.pendingResponses[requestID]
+	delete(q.pendingResponses, requestID)
+	q.mu.Unlock()
 
-	// Check if this is the init response
-	if requestID == q.initRequestID {
-		select {
-		case q.initResponseCh <- envelope.Response.Response:
-		default:
+	if !ok {
+		if q.opts.StderrCallback != nil {
+			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleControlResponse: no pending request_id=%s", requestID))
 		}
 		return
 	}
 
-	// Check for other pending responses
-	if ch, ok := q.pendingResponses[requestID]; ok {
-		select {
-		case ch <- envelope.Response.Response:
-		default:
+	res := controlResult{response: envelope.Response.Response}
+	if envelope.Response.Subtype == "error" {
+		res.err = &ControlError{
+			Subtype:   pending.subtype,
+			RequestID: requestID,
+			Message:   envelope.Response.Error,
 		}
-		delete(q.pendingResponses, requestID)
 	}
+	pending.ch <- res
 }
 
 // handleInitialize handles initialization requests.
@@ -393,87 +746,80 @@ func (q *QueryHandler) handleCanUseTool(ctx context.Context, req *CanUseToolRequ
 	}
 }
 
-// handleHookCallback handles hook callbacks.
+// handleHookCallback routes a hook callback to the callback registered under its ID.
+// The CLI has already applied the matcher, so the callback runs unconditionally.
 func (q *QueryHandler) handleHookCallback(ctx context.Context, req *HookCallbackRequest) *HookCallbackResponse {
-	// Extract event from callback_id if event field is empty
-	// CLI sends callback_id like "PreToolUse_callback" instead of event field
-	event := HookEvent(req.Event)
-	if event == "" && req.CallbackID != "" {
-		// Strip "_callback" suffix to get event name
-		eventStr := req.CallbackID
-		if len(eventStr) > 9 && eventStr[len(eventStr)-9:] == "_callback" {
-			eventStr = eventStr[:len(eventStr)-9]
-		}
-		event = HookEvent(eventStr)
+	reg, ok := q.hookCallbacks[req.CallbackID]
+	if !ok {
 		if q.opts.StderrCallback != nil {
-			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: extracted event=%s from callback_id=%s", event, req.CallbackID))
+			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: unknown callback_id=%s", req.CallbackID))
 		}
+		return &HookCallbackResponse{Continue: true}
 	}
 
-	matchers, ok := q.opts.Hooks[event]
-	if !ok || len(matchers) == 0 {
-		if q.opts.StderrCallback != nil {
-			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: no matchers for event=%s", event))
-		}
-		return &HookCallbackResponse{Continue: true}
+	input := req.Input
+	if input == nil {
+		input = &HookInput{}
+	}
+	if input.HookEventName == "" {
+		input.HookEventName = reg.event
+	}
+	if input.ToolUseID == "" {
+		input.ToolUseID = req.ToolUseID
 	}
 
 	if q.opts.StderrCallback != nil {
-		q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: event=%s matchers=%d tool=%s", event, len(matchers), req.Input.ToolName))
+		q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: event=%s callback_id=%s tool=%s", reg.event, req.CallbackID, input.ToolName))
 	}
 
-	for i, matcher := range matchers {
-		// Check if matcher applies to this tool
-		if matcher.ToolName != "*" && matcher.ToolName != req.Input.ToolName {
-			if q.opts.StderrCallback != nil {
-				q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: matcher[%d] skipped (tool mismatch: %s != %s)", i, matcher.ToolName, req.Input.ToolName))
-			}
-			continue
-		}
-
+	// The CLI only calls matching hooks; check again in case it did not.
+	if !reg.matcher.match(input.ToolName) {
 		if q.opts.StderrCallback != nil {
-			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: matcher[%d] matched, calling callback", i))
+			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: callback_id=%s does not match tool=%s, skipping", req.CallbackID, input.ToolName))
 		}
+		return &HookCallbackResponse{Continue: true}
+	}
 
-		// Apply timeout if specified
-		callCtx := ctx
+	// Apply timeout if specified
+	callCtx := ctx
+	if reg.timeout > 0 {
 		var cancel context.CancelFunc
-		if matcher.Timeout > 0 {
-			callCtx, cancel = context.WithTimeout(ctx, matcher.Timeout)
-		}
+		callCtx, cancel = context.WithTimeout(ctx, reg.timeout)
+		defer cancel()
+	}
 
-		output, err := matcher.Callback(callCtx, req.Input)
-		if cancel != nil {
-			cancel()
-		}
+	output, err := reg.callback(callCtx, input)
 
-		if q.opts.StderrCallback != nil {
-			q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: callback returned err=%v output=%+v", err, output))
-		}
+	if q.opts.StderrCallback != nil {
+		q.opts.StderrCallback(fmt.Sprintf("[clawde] handleHookCallback: callback returned err=%v output=%+v", err, output))
+	}
 
-		if err != nil {
-			return &HookCallbackResponse{
-				Continue: false,
-				Decision: "block",
-				Reason:   err.Error(),
-			}
+	if err != nil {
+		return &HookCallbackResponse{
+			Continue: false,
+			Decision: "block",
+			Reason:   err.Error(),
 		}
+	}
 
-		if output != nil && !output.Continue {
-			return &HookCallbackResponse{
-				Continue:      output.Continue,
-				Decision:      output.Decision,
-				Reason:        output.Reason,
-				StopReason:    output.StopReason,
-				ModifiedInput: output.ModifiedInput,
-			}
-		}
+	if output == nil {
+		return &HookCallbackResponse{Continue: true}
 	}
 
-	if q.opts.StderrCallback != nil {
-		q.opts.StderrCallback("[clawde] handleHookCallback: returning continue=true")
+	specific := output.SpecificOutput
+	if specific == nil && output.ModifiedInput != nil && reg.event == HookPreToolUse {
+		specific = PreToolUseOutput{UpdatedInput: output.ModifiedInput}
+	}
+
+	return &HookCallbackResponse{
+		Continue:           output.Continue,
+		SuppressOutput:     output.SuppressOutput,
+		StopReason:         output.StopReason,
+		Decision:           output.Decision,
+		Reason:             output.Reason,
+		SystemMessage:      output.SystemMessage,
+		HookSpecificOutput: encodeHookSpecificOutput(specific),
 	}
-	return &HookCallbackResponse{Continue: true}
 }
 
 // handleMCPMessage handles MCP server messages.
@@ -495,18 +841,88 @@ func (q *QueryHandler) handleMCPMessage(ctx context.Context, req *MCPMessageRequ
 	return &MCPMessageResponse{Result: result}
 }
 
+// initialSessionID returns the session ID known before the CLI reports one.
+func initialSessionID(opts *Options) string {
+	if opts.ResumeConversation != "" {
+		return opts.ResumeConversation
+	}
+	return opts.SessionID
+}
+
+// SessionID returns the ID of the session, as last reported by the CLI.
+func (q *QueryHandler) SessionID() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.sessionID
+}
+
+// setSessionID records a session ID reported by the CLI.
+func (q *QueryHandler) setSessionID(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.sessionID != sessionID && q.opts.StderrCallback != nil {
+		q.opts.StderrCallback(fmt.Sprintf("[clawde] session_id=%s", sessionID))
+	}
+	q.sessionID = sessionID
+}
+
+// promptSessionID returns the session ID to send with a prompt.
+func (q *QueryHandler) promptSessionID() string {
+	if id := q.SessionID(); id != "" {
+		return id
+	}
+	return "default"
+}
+
 // SendPrompt sends a user prompt.
 func (q *QueryHandler) SendPrompt(prompt string) error {
+	return q.sendUserMessage(prompt, "")
+}
+
+// SendBlocks sends a user prompt made of content blocks, such as text,
+// images and documents.
+func (q *QueryHandler) SendBlocks(blocks []ContentBlock) error {
+	content, err := encodeContentBlocks(blocks)
+	if err != nil {
+		return err
+	}
+	return q.sendUserMessage(content, "")
+}
+
+// SendInput sends a user message fed to Client.QueryStream.
+func (q *QueryHandler) SendInput(in UserInput) error {
+	var content any = in.Text
+	if len(in.Blocks) > 0 {
+		blocks, err := encodeContentBlocks(in.Blocks)
+		if err != nil {
+			return err
+		}
+		content = blocks
+	}
+	return q.sendUserMessage(content, in.ParentToolUseID)
+}
+
+// sendUserMessage writes a user message with the given content.
+func (q *QueryHandler) sendUserMessage(content any, parentToolUseID string) error {
+	var parent any
+	if parentToolUseID != "" {
+		parent = parentToolUseID
+	}
+
 	// Format must match what the CLI expects:
 	// {"type": "user", "message": {"role": "user", "content": "..."}, ...}
 	msg := map[string]any{
 		"type": "user",
 		"message": map[string]any{
 			"role":    "user",
-			"content": prompt,
+			"content": content,
 		},
-		"parent_tool_use_id": nil,
-		"session_id":         nil,
+		"parent_tool_use_id": parent,
+		"session_id":         q.promptSessionID(),
 	}
 
 	data, err := json.Marshal(msg)
@@ -514,9 +930,25 @@ func (q *QueryHandler) SendPrompt(prompt string) error {
 		return err
 	}
 
+	return q.write(data)
+}
+
+// write sends a line to the transport. Writes are serialized because
+// control responses are produced concurrently.
+func (q *QueryHandler) write(data []byte) error {
+	q.writeMu.Lock()
+	defer q.writeMu.Unlock()
 	return q.transport.Write(data)
 }
 
+// reportError delivers err on the error channel unless the handler is closed.
+func (q *QueryHandler) reportError(err error) {
+	select {
+	case q.errCh <- err:
+	case <-q.doneCh:
+	}
+}
+
 // Messages returns the message channel.
 func (q *QueryHandler) Messages() <-chan Message {
 	return q.msgCh
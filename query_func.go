@@ -2,8 +2,15 @@ package clawde
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 )
 
+// structuredOutputAttempts is how many times QueryAs asks for output
+// matching its schema.
+const structuredOutputAttempts = 2
+
 // Query performs a one-shot query and returns a stream.
 // This is a convenience function that creates a client, connects, queries, and handles cleanup.
 func Query(ctx context.Context, prompt string, opts ...Option) (*Stream, error) {
@@ -49,3 +56,85 @@ func QueryResult(ctx context.Context, prompt string, opts ...Option) ([]Message,
 
 	return stream.Collect()
 }
+
+// QueryAs performs a one-shot query and decodes its structured output into
+// a T. The output schema is derived from T. If the output does not match
+// it, the query is retried once with the validation errors; a second
+// mismatch returns a *ValidationError.
+func QueryAs[T any](ctx context.Context, prompt string, opts ...Option) (T, *ResultMessage, error) {
+	var out T
+	schema, err := json.Marshal(generateSchema(out))
+	if err != nil {
+		return out, nil, err
+	}
+	compiled, err := compileSchema(schema)
+	if err != nil {
+		return out, nil, err
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithOutputSchema(json.RawMessage(schema)))
+	client, err := NewClient(opts...)
+	if err != nil {
+		return out, nil, err
+	}
+	if err := client.Connect(ctx); err != nil {
+		return out, nil, err
+	}
+	defer client.Close()
+
+	var result *ResultMessage
+	for attempt := 1; ; attempt++ {
+		stream, err := client.Query(ctx, prompt)
+		if err != nil {
+			return out, result, err
+		}
+		err = stream.Wait()
+		result = stream.Result()
+		if err != nil {
+			return out, result, err
+		}
+
+		var v T
+		err = decodeStructuredOutput(compiled, result, &v)
+		if err == nil {
+			return v, result, nil
+		}
+		if attempt == structuredOutputAttempts {
+			return out, result, err
+		}
+		prompt = retryPrompt(err)
+	}
+}
+
+// decodeStructuredOutput validates a result's structured output against
+// schema and unmarshals it into v.
+func decodeStructuredOutput(schema *compiledSchema, result *ResultMessage, v any) error {
+	if result == nil {
+		return ErrStreamClosed
+	}
+	if len(result.StructuredOutput) == 0 {
+		return &ValidationError{Violations: []SchemaViolation{{Path: "$", Message: "no structured output in result"}}}
+	}
+	if err := schema.validate(result.StructuredOutput); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(result.StructuredOutput, v); err != nil {
+		return &ValidationError{Violations: []SchemaViolation{{Path: "$", Message: err.Error()}}}
+	}
+	return nil
+}
+
+// retryPrompt asks the model to correct output that failed validation.
+func retryPrompt(err error) string {
+	var b strings.Builder
+	b.WriteString("Your structured output did not match the required JSON schema:\n")
+	if verr, ok := err.(*ValidationError); ok {
+		for _, v := range verr.Violations {
+			fmt.Fprintf(&b, "- %s\n", v)
+		}
+	} else {
+		fmt.Fprintf(&b, "- %v\n", err)
+	}
+	b.WriteString("Respond again with output that matches the schema.")
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package clawde_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestQueryAs(t *testing.T) {
+	type recipe struct {
+		Name  string   `json:"name"`
+		Steps []string `json:"steps"`
+	}
+	bad := clawdetest.ResultWith(map[string]any{"structured_output": map[string]any{"name": 1}})
+	good := clawdetest.ResultWith(map[string]any{"structured_output": map[string]any{
+		"name":  "Scrambled eggs",
+		"steps": []string{"Whisk", "Cook"},
+	}})
+
+	t.Run("retry", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		ft := clawdetest.NewTransport(
+			clawdetest.WaitForPrompt(), bad,
+			clawdetest.WaitForPrompt(), good,
+		)
+		got, result, err := clawde.QueryAs[recipe](ctx, "eggs", clawde.WithTransport(ft))
+		if err != nil {
+			t.Fatalf("QueryAs: %v", err)
+		}
+		if got.Name != "Scrambled eggs" || len(got.Steps) != 2 || result == nil {
+			t.Errorf("QueryAs = %+v, %v", got, result)
+		}
+
+		prompts := ft.Prompts()
+		if len(prompts) != 2 {
+			t.Fatalf("sent %d prompts, want 2", len(prompts))
+		}
+		for _, want := range []string{`$.name: expected string, got integer`, `$: missing required property \"steps\"`} {
+			if !strings.Contains(string(prompts[1]), want) {
+				t.Errorf("retry prompt %s does not mention %s", prompts[1], want)
+			}
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		ft := clawdetest.NewTransport(
+			clawdetest.WaitForPrompt(), bad,
+			clawdetest.WaitForPrompt(), bad,
+		)
+		_, _, err := clawde.QueryAs[recipe](ctx, "eggs", clawde.WithTransport(ft))
+		var verr *clawde.ValidationError
+		if !errors.As(err, &verr) || len(verr.Violations) != 2 {
+			t.Fatalf("QueryAs error = %v, want a *ValidationError with 2 violations", err)
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package clawde_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestSlowCallbackDoesNotStallStream(t *testing.T) {
+	release := make(chan struct{})
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.Concurrently(
+			clawdetest.CanUseTool("Slow", map[string]any{}),
+			clawdetest.CanUseTool("Fast", map[string]any{}),
+			clawdetest.AssistantText("still streaming"),
+		),
+		clawdetest.Result("ok"),
+	)
+	ctx, client := connect(t, ft,
+		clawde.WithPermissionCallback(func(ctx context.Context, req *clawde.PermissionRequest) clawde.PermissionResult {
+			if req.ToolName == "Slow" {
+				select {
+				case <-release:
+				case <-ctx.Done():
+				}
+			}
+			return clawde.Allow()
+		}),
+	)
+
+	stream, err := client.Query(ctx, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for stream.Next() {
+		if am, ok := stream.Current().(*clawde.AssistantMessage); ok && am.Text() == "still streaming" {
+			close(release)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if err := ft.Err(); err != nil {
+		t.Fatalf("script: %v", err)
+	}
+
+	responses := ft.Responses()
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2", len(responses))
+	}
+	if responses[0].RequestID == responses[1].RequestID {
+		t.Error("responses share a request ID")
+	}
+}
+
+func TestCancelledCallbackSendsNoResponse(t *testing.T) {
+	cancelled := make(chan struct{})
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.Concurrently(
+			clawdetest.CanUseTool("Slow", map[string]any{}),
+			clawdetest.Sequence(clawdetest.Sleep(50*time.Millisecond), clawdetest.CancelPending()),
+		),
+		clawdetest.StepFunc(func(ctx context.Context, t *clawdetest.Transport) error {
+			select {
+			case <-cancelled:
+			case <-ctx.Done():
+			}
+			// Give the SDK a moment to (not) write the late response.
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}),
+		clawdetest.Result("ok"),
+	)
+	ctx, client := connect(t, ft,
+		clawde.WithPermissionCallback(func(ctx context.Context, req *clawde.PermissionRequest) clawde.PermissionResult {
+			<-ctx.Done()
+			close(cancelled)
+			return clawde.Allow()
+		}),
+	)
+	runQuery(t, ctx, client, ft, "go")
+
+	if n := len(ft.Responses()); n != 0 {
+		t.Errorf("got %d responses, want 0", n)
+	}
+	if stray := ft.StrayResponses(); len(stray) != 0 {
+		t.Errorf("SDK answered cancelled request: %+v", stray[0])
+	}
+}
+
+func TestRejectsBadControlRequestIDs(t *testing.T) {
+	canUseTool := func(requestID string) json.RawMessage {
+		return json.RawMessage(`{"type":"control_request","request_id":"` + requestID + `","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{}}}`)
+	}
+	// waitForStray waits until the SDK has answered n requests the script
+	// did not track.
+	waitForStray := func(ctx context.Context, ft *clawdetest.Transport, n int) error {
+		for len(ft.StrayResponses()) < n {
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+	release := make(chan struct{})
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.Emit(canUseTool("req_1")),
+		clawdetest.Emit(canUseTool("req_1")),
+		clawdetest.Emit(canUseTool("")),
+		clawdetest.StepFunc(func(ctx context.Context, ft *clawdetest.Transport) error {
+			if err := waitForStray(ctx, ft, 2); err != nil {
+				return err
+			}
+			close(release)
+			return waitForStray(ctx, ft, 3)
+		}),
+		clawdetest.Result("ok"),
+	)
+	var calls atomic.Int32
+	ctx, client := connect(t, ft,
+		clawde.WithPermissionCallback(func(ctx context.Context, req *clawde.PermissionRequest) clawde.PermissionResult {
+			calls.Add(1)
+			<-release
+			return clawde.Allow()
+		}),
+	)
+	runQuery(t, ctx, client, ft, "go")
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("permission callback ran %d times, want 1", n)
+	}
+	responses := ft.StrayResponses()
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3", len(responses))
+	}
+	wantErrors := map[string]string{
+		"req_1": "control request req_1 is already in flight",
+		"":      "control request without request_id",
+	}
+	for _, r := range responses[:2] {
+		if want, ok := wantErrors[r.RequestID]; !ok || r.Error != want {
+			t.Errorf("response to %q: error %q", r.RequestID, r.Error)
+		}
+		delete(wantErrors, r.RequestID)
+	}
+	if r := responses[2]; r.RequestID != "req_1" || r.Error != "" {
+		t.Errorf("response to the first request = %+v", r)
+	}
+}
+
+func TestRejectsUnhandledControlRequests(t *testing.T) {
+	answered := make(chan struct{})
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.Emit(json.RawMessage(`{"type":"control_request","request_id":"req_1","request":{"subtype":"rewind_files"}}`)),
+		clawdetest.Emit(json.RawMessage(`{"type":"control_request","request_id":"req_2","request":{"subtype":"can_use_tool","tool_name":7}}`)),
+		clawdetest.StepFunc(func(ctx context.Context, ft *clawdetest.Transport) error {
+			for len(ft.StrayResponses()) < 2 {
+				select {
+				case <-time.After(10 * time.Millisecond):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			close(answered)
+			return nil
+		}),
+		clawdetest.Result("ok"),
+	)
+	ctx, client := connect(t, ft)
+
+	stream, err := client.Query(ctx, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A malformed request fails the turn; an unknown subtype does not.
+	if err := stream.Wait(); err == nil || !strings.Contains(err.Error(), "tool_name") {
+		t.Errorf("err = %v, want the parse error of req_2", err)
+	}
+	select {
+	case <-answered:
+	case <-ctx.Done():
+		t.Fatalf("script: %v", ft.Err())
+	}
+
+	wantErrors := map[string]string{
+		"req_1": "unknown request subtype: rewind_files",
+		"req_2": "cannot unmarshal number",
+	}
+	responses := ft.StrayResponses()
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2", len(responses))
+	}
+	for _, r := range responses {
+		if want, ok := wantErrors[r.RequestID]; !ok || !strings.Contains(r.Error, want) {
+			t.Errorf("response to %q: error %q", r.RequestID, r.Error)
+		}
+		delete(wantErrors, r.RequestID)
+	}
+}
+
+func TestInterruptControlRequest(t *testing.T) {
+	ft := clawdetest.NewTransport()
+	interrupts := 0
+	ft.HandleControl("interrupt", func(request json.RawMessage) (any, error) {
+		interrupts++
+		if interrupts > 1 {
+			return nil, errors.New("no query running")
+		}
+		return map[string]any{}, nil
+	})
+	ctx, client := connect(t, ft)
+
+	if err := client.Interrupt(); err != nil {
+		t.Fatalf("Interrupt: %v", err)
+	}
+	if interrupts != 1 {
+		t.Errorf("CLI saw %d interrupts, want 1", interrupts)
+	}
+
+	err := client.InterruptContext(ctx)
+	var ctrlErr *clawde.ControlError
+	if !errors.As(err, &ctrlErr) {
+		t.Fatalf("err = %v, want *ControlError", err)
+	}
+	if ctrlErr.Subtype != "interrupt" || ctrlErr.Message != "no query running" {
+		t.Errorf("ControlError = %+v", ctrlErr)
+	}
+}
+
+func TestInterruptedResult(t *testing.T) {
+	interrupted := make(chan struct{})
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.AssistantText("working"),
+		clawdetest.StepFunc(func(ctx context.Context, t *clawdetest.Transport) error {
+			select {
+			case <-interrupted:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}),
+		clawdetest.ResultWith(map[string]any{"subtype": "error_during_execution", "is_error": true}),
+	)
+	ft.HandleControl("interrupt", func(request json.RawMessage) (any, error) {
+		close(interrupted)
+		return map[string]any{}, nil
+	})
+	ctx, client := connect(t, ft)
+
+	stream, err := client.Query(ctx, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for stream.Next() {
+		if _, ok := stream.Current().(*clawde.AssistantMessage); ok {
+			if err := client.InterruptContext(ctx); err != nil {
+				t.Fatalf("Interrupt: %v", err)
+			}
+		}
+	}
+	if err := stream.Err(); !errors.Is(err, clawde.ErrInterrupted) {
+		t.Errorf("err = %v, want ErrInterrupted", err)
+	}
+}
@@ -0,0 +1,450 @@
+package clawde
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	numberType        = reflect.TypeOf(json.Number(""))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// generateSchema generates a JSON schema from a Go type.
+//
+// Fields are named and made optional following encoding/json: a field is
+// required unless tagged omitempty or omitzero, and pointer fields also
+// accept null. A description tag and a
+// jsonschema tag add keywords to a field's schema, and properties not
+// declared by the struct are not allowed:
+//
+//	Unit  string   `json:"unit" jsonschema:"enum=celsius|fahrenheit,default=celsius"`
+//	Days  int      `json:"days" jsonschema:"min=1,max=14"`
+//	Email string   `json:"email,omitempty" jsonschema:"format=email,required"`
+//	Tags  []string `json:"tags" jsonschema:"minItems=1,enum=a|b|c"`
+//
+// Supported keys are enum, default, min, max, exclusiveMin, exclusiveMax,
+// minLength, maxLength, pattern, format, minItems, maxItems, uniqueItems,
+// title, description, required and optional. Commas in values are escaped
+// as \,. Named struct types used more than once, including recursive
+// types, are defined once under $defs.
+func generateSchema(v any) map[string]any {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return map[string]any{"type": "object"}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	r := &schemaReflector{
+		root:  t,
+		uses:  make(map[reflect.Type]int),
+		names: make(map[reflect.Type]string),
+		taken: make(map[string]bool),
+		defs:  make(map[string]any),
+	}
+	r.count(t, make(map[reflect.Type]bool))
+
+	schema := r.typeSchema(t, true)
+	if len(r.defs) > 0 {
+		schema["$defs"] = r.defs
+	}
+	return schema
+}
+
+// schemaReflector builds the schema of one root type.
+type schemaReflector struct {
+	root  reflect.Type
+	uses  map[reflect.Type]int    // references to each named struct type
+	names map[reflect.Type]string // $defs names of types defined so far
+	taken map[string]bool
+	defs  map[string]any
+}
+
+// count records how often each named struct type reachable from t is used.
+func (r *schemaReflector) count(t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		r.count(t.Elem(), seen)
+	case reflect.Struct:
+		if isOpaqueType(t) {
+			return
+		}
+		if t.Name() != "" {
+			r.uses[t]++
+		}
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		for _, f := range schemaFields(t, nil) {
+			r.count(f.typ, seen)
+		}
+	}
+}
+
+// typeSchema returns the schema of t. top is true for the root type only.
+func (r *schemaReflector) typeSchema(t reflect.Type, top bool) map[string]any {
+	if t.Kind() == reflect.Ptr {
+		// A nil pointer is encoded as null.
+		return nullable(r.typeSchema(t.Elem(), top))
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t == numberType:
+		return map[string]any{"type": "number"}
+	case implements(t, jsonMarshalerType):
+		// The type encodes itself; its fields say nothing about the JSON.
+		return map[string]any{}
+	case implements(t, textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": r.typeSchema(t.Elem(), false)}
+	case reflect.Array:
+		return map[string]any{
+			"type":     "array",
+			"items":    r.typeSchema(t.Elem(), false),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": r.typeSchema(t.Elem(), false)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		if t == r.root {
+			if top {
+				return r.structSchema(t)
+			}
+			return map[string]any{"$ref": "#"}
+		}
+		if r.uses[t] > 1 {
+			return map[string]any{"$ref": "#/$defs/" + r.define(t)}
+		}
+		return r.structSchema(t)
+	default:
+		// Interfaces can hold any value.
+		return map[string]any{}
+	}
+}
+
+// nullable extends schema to accept null as well.
+func nullable(schema map[string]any) map[string]any {
+	switch t := schema["type"].(type) {
+	case string:
+		schema["type"] = []any{t, "null"}
+		return schema
+	case nil:
+		if len(schema) == 0 {
+			return schema // already accepts anything
+		}
+		return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+	}
+	return schema
+}
+
+// define adds t to $defs unless it is there already and returns its name.
+func (r *schemaReflector) define(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+
+	base := strings.Map(func(c rune) rune {
+		if c == '_' || c == '.' || c == '-' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+			return c
+		}
+		return '_'
+	}, t.Name())
+	name := base
+	for i := 2; r.taken[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	r.taken[name] = true
+	r.names[t] = name
+
+	r.defs[name] = r.structSchema(t)
+	return name
+}
+
+// structSchema returns the object schema of a struct type's fields.
+func (r *schemaReflector) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+
+	for _, f := range schemaFields(t, nil) {
+		prop := r.typeSchema(f.typ, false)
+		if f.quoted {
+			prop = map[string]any{"type": "string"}
+		}
+		if desc := f.tag.Get("description"); desc != "" {
+			prop["description"] = desc
+		}
+
+		isRequired := !f.omit
+		for _, kv := range splitSchemaTag(f.tag.Get("jsonschema")) {
+			switch kv[0] {
+			case "required":
+				isRequired = true
+			case "optional":
+				isRequired = false
+			default:
+				applySchemaKeyword(prop, f.typ, kv[0], kv[1])
+			}
+		}
+
+		if enum, ok := prop["enum"].([]any); ok && f.typ.Kind() == reflect.Ptr {
+			prop["enum"] = append(enum, nil)
+		}
+
+		properties[f.name] = prop
+		if isRequired {
+			required = append(required, f.name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaField is a struct field as encoding/json sees it.
+type schemaField struct {
+	name   string
+	typ    reflect.Type
+	tag    reflect.StructTag
+	omit   bool // omitempty or omitzero
+	quoted bool // encoded as a string with the ,string option
+}
+
+// schemaFields returns the JSON fields of a struct type, including fields
+// promoted from embedded structs. Shallower fields hide deeper ones.
+func schemaFields(t reflect.Type, visiting map[reflect.Type]bool) []schemaField {
+	if visiting == nil {
+		visiting = make(map[reflect.Type]bool)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []schemaField
+	var promoted [][]schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		jsonTag := f.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		parts := strings.Split(jsonTag, ",")
+		name := parts[0]
+
+		if f.Anonymous && name == "" {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct && !isOpaqueType(et) {
+				if !visiting[et] {
+					promoted = append(promoted, schemaFields(et, visiting))
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		field := schemaField{name: f.Name, typ: f.Type, tag: f.Tag}
+		if name != "" {
+			field.name = name
+		}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "omitempty", "omitzero":
+				field.omit = true
+			case "string":
+				switch f.Type.Kind() {
+				case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+					reflect.Float32, reflect.Float64, reflect.String:
+					field.quoted = true
+				}
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		seen[f.name] = true
+	}
+	for _, group := range promoted {
+		for _, f := range group {
+			if !seen[f.name] {
+				seen[f.name] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// splitSchemaTag splits a jsonschema tag into key/value pairs.
+func splitSchemaTag(tag string) [][2]string {
+	if tag == "" {
+		return nil
+	}
+
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			b.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(tag[i])
+		}
+	}
+	parts = append(parts, b.String())
+
+	pairs := make([][2]string, 0, len(parts))
+	for _, part := range parts {
+		key, value, _ := strings.Cut(part, "=")
+		if key = strings.TrimSpace(key); key != "" {
+			pairs = append(pairs, [2]string{key, value})
+		}
+	}
+	return pairs
+}
+
+// applySchemaKeyword sets the keyword for a jsonschema tag key on the
+// schema of a field of type t. Values that do not parse are ignored.
+func applySchemaKeyword(schema map[string]any, t reflect.Type, key, value string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch key {
+	case "enum":
+		// An enum on a list constrains its items.
+		target, vt := schema, t
+		if items, ok := schema["items"].(map[string]any); ok && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			target, vt = items, t.Elem()
+		}
+		var values []any
+		for _, s := range strings.Split(value, "|") {
+			if v, ok := parseSchemaValue(vt, s); ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			target["enum"] = values
+		}
+
+	case "default":
+		if v, ok := parseSchemaValue(t, value); ok {
+			schema["default"] = v
+		}
+
+	case "min", "max", "exclusiveMin", "exclusiveMax":
+		keyword := map[string]string{
+			"min":          "minimum",
+			"max":          "maximum",
+			"exclusiveMin": "exclusiveMinimum",
+			"exclusiveMax": "exclusiveMaximum",
+		}[key]
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			schema[keyword] = n
+		}
+
+	case "minLength", "maxLength", "minItems", "maxItems":
+		if n, err := strconv.Atoi(value); err == nil {
+			schema[key] = n
+		}
+
+	case "uniqueItems":
+		schema[key] = value == "" || value == "true"
+
+	case "pattern", "format", "title", "description":
+		schema[key] = value
+	}
+}
+
+// parseSchemaValue parses a tag value as a JSON value of type t.
+func parseSchemaValue(t reflect.Type, s string) (any, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return s, true
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, err == nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return n, err == nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		return n, err == nil
+	default:
+		var v any
+		err := json.Unmarshal([]byte(s), &v)
+		return v, err == nil
+	}
+}
+
+// isOpaqueType reports whether a struct type's schema does not come from
+// its fields.
+func isOpaqueType(t reflect.Type) bool {
+	return t == timeType || implements(t, jsonMarshalerType) || implements(t, textMarshalerType)
+}
+
+// implements reports whether t or *t implements iface.
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Ptr && reflect.PointerTo(t).Implements(iface))
+}
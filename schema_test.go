@@ -0,0 +1,124 @@
+package clawde
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type schemaAddress struct {
+	City string `json:"city"`
+}
+
+type schemaBase struct {
+	ID      string    `json:"id" jsonschema:"pattern=^[a-z]+$"`
+	Created time.Time `json:"created"`
+}
+
+type schemaNode struct {
+	Name     string       `json:"name"`
+	Children []schemaNode `json:"children,omitempty"`
+}
+
+type schemaInput struct {
+	schemaBase
+	Unit     string            `json:"unit" jsonschema:"enum=celsius|fahrenheit,default=celsius"`
+	Days     int               `json:"days" jsonschema:"min=1,max=14" description:"Forecast length"`
+	Level    uint8             `json:"level,omitzero" jsonschema:"enum=1|2|3"`
+	Tags     []string          `json:"tags,omitempty" jsonschema:"enum=a|b,minItems=1"`
+	Pattern  string            `json:"pattern,omitempty" jsonschema:"pattern=^a\\,b$,required"`
+	Home     schemaAddress     `json:"home"`
+	Note     *string           `json:"note" jsonschema:"enum=x|y"`
+	Work     *schemaAddress    `json:"work,omitempty"`
+	Tree     *schemaNode       `json:"tree,omitempty"`
+	Extra    json.RawMessage   `json:"extra,omitempty"`
+	Anything any               `json:"anything,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Count    int64             `json:"count,string,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+	internal string
+}
+
+func TestGenerateSchema(t *testing.T) {
+	schema := generateSchema(schemaInput{})
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	props := got["properties"].(map[string]any)
+	prop := func(name string) string {
+		b, _ := json.Marshal(props[name])
+		return string(b)
+	}
+
+	want := map[string]string{
+		"id":       `{"pattern":"^[a-z]+$","type":"string"}`,
+		"created":  `{"format":"date-time","type":"string"}`,
+		"unit":     `{"default":"celsius","enum":["celsius","fahrenheit"],"type":"string"}`,
+		"days":     `{"description":"Forecast length","maximum":14,"minimum":1,"type":"integer"}`,
+		"level":    `{"enum":[1,2,3],"minimum":0,"type":"integer"}`,
+		"tags":     `{"items":{"enum":["a","b"],"type":"string"},"minItems":1,"type":"array"}`,
+		"pattern":  `{"pattern":"^a,b$","type":"string"}`,
+		"home":     `{"$ref":"#/$defs/schemaAddress"}`,
+		"note":     `{"enum":["x","y",null],"type":["string","null"]}`,
+		"work":     `{"anyOf":[{"$ref":"#/$defs/schemaAddress"},{"type":"null"}]}`,
+		"tree":     `{"anyOf":[{"$ref":"#/$defs/schemaNode"},{"type":"null"}]}`,
+		"extra":    `{}`,
+		"anything": `{}`,
+		"labels":   `{"additionalProperties":{"type":"string"},"type":"object"}`,
+		"count":    `{"type":"string"}`,
+		"data":     `{"contentEncoding":"base64","type":"string"}`,
+	}
+	for name, w := range want {
+		if g := prop(name); g != w {
+			t.Errorf("%s = %s, want %s", name, g, w)
+		}
+	}
+	if len(props) != len(want) {
+		t.Errorf("got %d properties, want %d: %s", len(props), len(want), data)
+	}
+
+	required, _ := json.Marshal(got["required"])
+	if string(required) != `["unit","days","pattern","home","note","id","created"]` {
+		t.Errorf("required = %s", required)
+	}
+
+	// Pointer fields accept null, as encoding/json does.
+	valid := `{"id":"a","created":"2026-10-16T08:00:00Z","unit":"celsius","days":3,"pattern":"a,b","home":{"city":"Oslo"},"note":null,"work":null}`
+	if err := validateJSON(data, json.RawMessage(valid)); err != nil {
+		t.Errorf("validate(%s): %v", valid, err)
+	}
+
+	defs, _ := json.Marshal(got["$defs"])
+	wantDefs := `{"schemaAddress":{"additionalProperties":false,"properties":{"city":{"type":"string"}},"required":["city"],"type":"object"},` +
+		`"schemaNode":{"additionalProperties":false,"properties":{"children":{"items":{"$ref":"#/$defs/schemaNode"},"type":"array"},"name":{"type":"string"}},"required":["name"],"type":"object"}}`
+	if string(defs) != wantDefs {
+		t.Errorf("$defs = %s\nwant %s", defs, wantDefs)
+	}
+}
+
+func TestGenerateSchemaRecursiveRoot(t *testing.T) {
+	schema, err := json.Marshal(generateSchema(&schemaNode{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"additionalProperties":false,"properties":{"children":{"items":{"$ref":"#"},"type":"array"},"name":{"type":"string"}},"required":["name"],"type":"object"}`
+	if string(schema) != want {
+		t.Errorf("schema = %s\nwant %s", schema, want)
+	}
+
+	valid := `{"name":"a","children":[{"name":"b","children":[{"name":"c"}]}]}`
+	if err := validateJSON(schema, json.RawMessage(valid)); err != nil {
+		t.Errorf("validate(%s): %v", valid, err)
+	}
+	invalid := `{"name":"a","children":[{"children":[{"name":2}]}]}`
+	err = validateJSON(schema, json.RawMessage(invalid))
+	if err == nil || err.Error() != `clawde: value does not match schema: $.children[0]: missing required property "name"; $.children[0].children[0].name: expected string, got integer` {
+		t.Errorf("validate(%s) = %v", invalid, err)
+	}
+}
@@ -2,6 +2,7 @@ package clawde
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
@@ -64,8 +65,21 @@ func (s *Session) Send(ctx context.Context, message string) error {
 	return s.client.Send(ctx, message)
 }
 
-// Stream returns a channel of messages from the session.
-// Call this after Send() to receive Claude's response.
+// SendMessage sends a message made of content blocks, such as text, images
+// and documents, to the session.
+func (s *Session) SendMessage(ctx context.Context, blocks ...ContentBlock) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrSessionClosed
+	}
+	s.mu.RUnlock()
+
+	return s.client.SendBlocks(ctx, blocks...)
+}
+
+// Stream returns a stream of the response to the oldest message sent with
+// Send that has no stream yet, or ErrNoPendingPrompt if there is none.
 func (s *Session) Stream(ctx context.Context) (*Stream, error) {
 	s.mu.RLock()
 	if s.closed {
@@ -74,18 +88,44 @@ func (s *Session) Stream(ctx context.Context) (*Stream, error) {
 	}
 	s.mu.RUnlock()
 
-	// Get the message channel from the query handler
-	msgCh := s.client.Receive(ctx)
-	errCh := make(chan error, 1)
+	return s.client.stream(ctx)
+}
+
+// SetPermissionMode changes the permission mode of the session.
+func (s *Session) SetPermissionMode(ctx context.Context, mode PermissionMode) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrSessionClosed
+	}
+	s.mu.RUnlock()
 
-	return NewStream(ctx, msgCh, errCh), nil
+	return s.client.SetPermissionMode(ctx, mode)
 }
 
-// SessionID returns the current session ID.
-// This may be empty until the first response is received.
+// SetModel changes the model used for subsequent turns of the session.
+func (s *Session) SetModel(ctx context.Context, model string) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrSessionClosed
+	}
+	s.mu.RUnlock()
+
+	return s.client.SetModel(ctx, model)
+}
+
+// SessionID returns the current session ID, as last reported by the CLI.
+// After Close it returns the last known ID.
 func (s *Session) SessionID() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+
+	if !s.closed {
+		if id := s.client.SessionID(); id != "" {
+			return id
+		}
+	}
 	return s.sessionID
 }
 
@@ -96,6 +136,11 @@ func (s *Session) Close() error {
 		s.mu.Unlock()
 		return nil
 	}
+	if s.client != nil {
+		if id := s.client.SessionID(); id != "" {
+			s.sessionID = id
+		}
+	}
 	s.closed = true
 	s.mu.Unlock()
 
@@ -136,8 +181,12 @@ func Prompt(ctx context.Context, prompt string, opts ...Option) (*PromptResult,
 			result.DurationMS = m.DurationMS
 			result.NumTurns = m.NumTurns
 			result.SessionID = m.SessionID
+			result.ModelUsage = m.ModelUsage
+			result.PermissionDenials = m.PermissionDenials
+			result.StructuredOutput = m.StructuredOutput
 		}
 	}
+	result.Usage = stream.Usage()
 
 	if stream.Err() != nil {
 		return nil, stream.Err()
@@ -154,6 +203,11 @@ type PromptResult struct {
 	DurationMS   int64   // Duration in milliseconds
 	NumTurns     int     // Number of conversation turns
 	SessionID    string  // Session ID for resuming
+
+	Usage             Usage                 // Token usage of the query
+	ModelUsage        map[string]ModelUsage // Token usage and cost per model
+	PermissionDenials []PermissionDenial    // Tool uses denied during the query
+	StructuredOutput  json.RawMessage       // Output matching the output schema, if any
 }
 
 // ErrSessionClosed is returned when trying to use a closed session.
@@ -0,0 +1,68 @@
+package clawde_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestSessionIDTracking(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.System("init"),
+		clawdetest.Result("one"),
+		clawdetest.Reply("two"),
+	)
+	session, err := clawde.CreateSession(ctx, clawde.WithTransport(ft))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	generated := session.SessionID()
+	if len(generated) != 36 {
+		t.Fatalf("SessionID after connect = %q, want a UUID", generated)
+	}
+
+	for _, prompt := range []string{"one", "two"} {
+		if err := session.Send(ctx, prompt); err != nil {
+			t.Fatal(err)
+		}
+		stream, err := session.Stream(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Wait(); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	}
+	if got := session.SessionID(); got != clawdetest.DefaultSessionID {
+		t.Errorf("SessionID = %q, want %q from init message", got, clawdetest.DefaultSessionID)
+	}
+
+	var sent []string
+	for _, raw := range ft.Prompts() {
+		var msg struct {
+			SessionID string `json:"session_id"`
+		}
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, msg.SessionID)
+	}
+	if len(sent) != 2 || sent[0] != generated || sent[1] != clawdetest.DefaultSessionID {
+		t.Errorf("prompt session IDs = %q, want [%s %s]", sent, generated, clawdetest.DefaultSessionID)
+	}
+
+	session.Close()
+	if got := session.SessionID(); got != clawdetest.DefaultSessionID {
+		t.Errorf("SessionID after Close = %q", got)
+	}
+}
@@ -3,6 +3,9 @@ package clawde
 import (
 	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Stream provides iteration over query responses.
@@ -14,23 +17,100 @@ type Stream struct {
 	current       Message
 	err           error
 	done          bool
-	message       *AssistantMessage // accumulated message
-	result        *ResultMessage    // final result
-	managedClient *Client           // client to close when stream is done (set by Query func)
+	message       *AssistantMessage     // accumulated message
+	result        *ResultMessage        // final result
+	usage         Usage                 // summed usage of assistant messages in the current turn
+	totalUsage    Usage                 // usage of the turns that have ended
+	modelUsage    map[string]ModelUsage // per-model usage of the turns that have ended
+	turnErr       error                 // error result of the latest turn (set in multi-turn streams)
+	firstTurnErr  error                 // first error result of any turn, reported by Err
+	usageSeen     map[string]bool       // API message IDs already counted
+	managedClient *Client               // client to close when stream is done (set by Query func)
+	abandon       func()                // discards the rest of the turn (set by Client)
+	multiTurn     bool                  // results do not end the stream (set by QueryStream)
+	partial       *MessageAccumulator   // in-progress message from partial events
+	finished      chan struct{}         // closed when the stream is done
+	finishOnce    sync.Once
+	timedOut      atomic.Bool   // the turn timeout expired
+	abandoned     chan struct{} // closed when a timed-out turn is given up on
 }
 
 // NewStream creates a new response stream.
 func NewStream(ctx context.Context, msgCh <-chan Message, errCh <-chan error) *Stream {
 	ctx, cancel := context.WithCancel(ctx)
 	return &Stream{
-		ctx:     ctx,
-		cancel:  cancel,
-		msgCh:   msgCh,
-		errCh:   errCh,
-		message: &AssistantMessage{Role: "assistant"},
+		ctx:       ctx,
+		cancel:    cancel,
+		msgCh:     msgCh,
+		errCh:     errCh,
+		message:   &AssistantMessage{Role: "assistant"},
+		usageSeen: make(map[string]bool),
+		finished:  make(chan struct{}),
+		partial:   NewMessageAccumulator(),
 	}
 }
 
+// watchTimeout enforces a turn timeout, counted from when active is closed,
+// i.e. when the CLI starts answering the turn. When it expires, interrupt is
+// called and the stream waits up to grace for the result; after that the
+// turn is abandoned and giveUp is called. Either way the stream ends with
+// ErrTimeout.
+func (s *Stream) watchTimeout(active <-chan struct{}, timeout, grace time.Duration, interrupt func(context.Context) error, giveUp func()) {
+	s.abandoned = make(chan struct{})
+
+	go func() {
+		select {
+		case <-active:
+		case <-s.finished:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-s.finished:
+			return
+		}
+		s.timedOut.Store(true)
+
+		ctx, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+		interrupt(ctx)
+
+		select {
+		case <-ctx.Done():
+		case <-s.finished:
+			return
+		}
+		close(s.abandoned)
+		giveUp()
+	}()
+}
+
+// finish marks the stream as done.
+func (s *Stream) finish() {
+	s.done = true
+	s.finishOnce.Do(func() {
+		close(s.finished)
+		if s.abandon != nil {
+			s.abandon()
+		}
+	})
+}
+
+// fail ends the stream with err, or ErrTimeout if the turn timed out.
+func (s *Stream) fail(err error) {
+	if s.timedOut.Load() {
+		err = ErrTimeout
+	}
+	s.err = err
+	s.finish()
+}
+
 // Next advances to the next message. Returns false when done.
 func (s *Stream) Next() bool {
 	if s.done {
@@ -39,20 +119,31 @@ func (s *Stream) Next() bool {
 
 	select {
 	case <-s.ctx.Done():
-		s.err = s.ctx.Err()
-		s.done = true
+		s.fail(s.ctx.Err())
+		return false
+
+	case <-s.abandoned:
+		s.fail(ErrTimeout)
 		return false
 
 	case err := <-s.errCh:
 		if err != nil {
-			s.err = err
-			s.done = true
+			s.fail(err)
 			return false
 		}
 
 	case msg, ok := <-s.msgCh:
 		if !ok {
-			s.done = true
+			// Report an error that was queued before the channel closed.
+			var err error
+			select {
+			case err = <-s.errCh:
+			default:
+			}
+			if err == nil {
+				err = s.firstTurnErr
+			}
+			s.fail(err)
 			return false
 		}
 
@@ -62,7 +153,19 @@ func (s *Stream) Next() bool {
 		// Check for result message (end of stream)
 		if result, ok := msg.(*ResultMessage); ok {
 			s.result = result
-			s.done = true
+			s.endTurn(result)
+			if s.multiTurn {
+				s.turnErr = resultError(result)
+				if s.firstTurnErr == nil {
+					s.firstTurnErr = s.turnErr
+				}
+				return true
+			}
+			s.err = resultError(result)
+			if s.timedOut.Load() {
+				s.err = &ResultError{Result: result, Err: ErrTimeout}
+			}
+			s.finish()
 			return true
 		}
 
@@ -75,8 +178,50 @@ func (s *Stream) Next() bool {
 // accumulate adds content to the accumulated message.
 func (s *Stream) accumulate(msg Message) {
 	switch m := msg.(type) {
+	case *StreamEvent:
+		s.partial.Add(m)
 	case *AssistantMessage:
 		s.message.Content = append(s.message.Content, m.Content...)
+		if m.Model != "" {
+			s.message.Model = m.Model
+		}
+		if m.StopReason != "" {
+			s.message.StopReason = m.StopReason
+		}
+		// The CLI splits one API message into several assistant messages
+		// sharing an ID and usage; count each API message once.
+		if m.Usage != nil && (m.ID == "" || !s.usageSeen[m.ID]) {
+			s.usageSeen[m.ID] = true
+			s.usage.Add(*m.Usage)
+		}
+	}
+}
+
+// endTurn adds the usage of a finished turn to the stream totals. The
+// result's totals replace the sum over its assistant messages.
+func (s *Stream) endTurn(result *ResultMessage) {
+	if result.Usage != nil {
+		s.totalUsage.Add(*result.Usage)
+	} else {
+		s.totalUsage.Add(s.usage)
+	}
+	s.usage = Usage{}
+
+	for model, u := range result.ModelUsage {
+		if s.modelUsage == nil {
+			s.modelUsage = make(map[string]ModelUsage)
+		}
+		total := s.modelUsage[model]
+		total.InputTokens += u.InputTokens
+		total.OutputTokens += u.OutputTokens
+		total.CacheReadInputTokens += u.CacheReadInputTokens
+		total.CacheCreationInputTokens += u.CacheCreationInputTokens
+		total.WebSearchRequests += u.WebSearchRequests
+		total.CostUSD += u.CostUSD
+		if u.ContextWindow > total.ContextWindow {
+			total.ContextWindow = u.ContextWindow
+		}
+		s.modelUsage[model] = total
 	}
 }
 
@@ -95,6 +240,36 @@ func (s *Stream) Result() *ResultMessage {
 	return s.result
 }
 
+// Partial returns the assistant message being streamed, rebuilt from
+// partial stream events. It is only populated when partial messages are
+// enabled with WithIncludePartialMessages.
+func (s *Stream) Partial() *AssistantMessage {
+	return s.partial.Message()
+}
+
+// Usage returns the token usage of the query: the totals from the result
+// message of each finished turn plus the sum over assistant messages of
+// the turn in progress.
+func (s *Stream) Usage() Usage {
+	u := s.totalUsage
+	u.Add(s.usage)
+	return u
+}
+
+// ModelUsage returns the per-model usage from the result messages received
+// so far, summed over turns.
+func (s *Stream) ModelUsage() map[string]ModelUsage {
+	return s.modelUsage
+}
+
+// TurnErr returns the error of the latest turn of a multi-turn stream
+// (see Client.QueryStream): a *ResultError when the result message just
+// returned by Current reports an error, nil otherwise. Err also reports
+// the first such error once the stream ends.
+func (s *Stream) TurnErr() error {
+	return s.turnErr
+}
+
 // Text returns the accumulated text content.
 func (s *Stream) Text() string {
 	return s.message.Text()
@@ -108,7 +283,7 @@ func (s *Stream) Err() error {
 // Close cancels the stream and closes the managed client if set.
 func (s *Stream) Close() error {
 	s.cancel()
-	s.done = true
+	s.finish()
 	if s.managedClient != nil {
 		return s.managedClient.Close()
 	}
@@ -0,0 +1,75 @@
+package clawde_test
+
+import (
+	"testing"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestUsageAggregation(t *testing.T) {
+	assistant := func(id, text string, usage map[string]any) clawdetest.Step {
+		return clawdetest.Emit(map[string]any{
+			"type": "assistant",
+			"message": map[string]any{
+				"id":          id,
+				"role":        "assistant",
+				"model":       "claude-test",
+				"content":     []any{map[string]any{"type": "text", "text": text}},
+				"stop_reason": "end_turn",
+				"usage":       usage,
+			},
+			"session_id": clawdetest.DefaultSessionID,
+		})
+	}
+	usage := map[string]any{"input_tokens": 10, "output_tokens": 5, "cache_read_input_tokens": 100}
+
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		// One API message split into two assistant messages.
+		assistant("msg_1", "hello ", usage),
+		assistant("msg_1", "world", usage),
+		assistant("msg_2", "!", map[string]any{"input_tokens": 1, "output_tokens": 1}),
+		clawdetest.ResultWith(map[string]any{
+			"result": "hello world!",
+			"modelUsage": map[string]any{
+				"claude-test": map[string]any{"inputTokens": 11, "outputTokens": 6, "costUSD": 0.01},
+			},
+			"permission_denials": []any{map[string]any{"tool_name": "Bash", "tool_use_id": "tu_1", "tool_input": map[string]any{"command": "rm"}}},
+		}),
+	)
+	ctx, client := connect(t, ft)
+
+	stream, err := client.Query(ctx, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first *clawde.AssistantMessage
+	for stream.Next() {
+		if am, ok := stream.Current().(*clawde.AssistantMessage); ok && first == nil {
+			first = am
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	if first.ID != "msg_1" || first.StopReason != "end_turn" || first.Usage == nil || first.Usage.CacheReadInputTokens != 100 {
+		t.Errorf("first assistant message = %+v", first)
+	}
+	want := clawde.Usage{InputTokens: 11, OutputTokens: 6, CacheReadInputTokens: 100}
+	if got := stream.Usage(); got != want {
+		t.Errorf("Usage() = %+v, want %+v", got, want)
+	}
+
+	result := stream.Result()
+	if result.Result != "hello world!" {
+		t.Errorf("Result = %q", result.Result)
+	}
+	if mu := stream.ModelUsage()["claude-test"]; mu.OutputTokens != 6 || mu.CostUSD != 0.01 {
+		t.Errorf("ModelUsage = %+v", stream.ModelUsage())
+	}
+	if len(result.PermissionDenials) != 1 || result.PermissionDenials[0].ToolName != "Bash" {
+		t.Errorf("PermissionDenials = %+v", result.PermissionDenials)
+	}
+}
@@ -15,25 +15,30 @@ import (
 
 // SubprocessTransport implements Transport using a subprocess.
 type SubprocessTransport struct {
-	opts   *Options
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout io.ReadCloser
-	stderr io.ReadCloser
-	msgCh  chan json.RawMessage
-	errCh  chan error
-	doneCh chan struct{}
-	mu     sync.Mutex
-	closed bool
+	opts       *Options
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stdout     io.ReadCloser
+	stderr     io.ReadCloser
+	stderrBuf  strings.Builder // reported in the ProcessError of a failed exit
+	msgCh      chan json.RawMessage
+	errCh      chan error
+	doneCh     chan struct{}
+	readDone   chan struct{} // closed when readLoop returns
+	stderrDone chan struct{} // closed when readStderr returns
+	mu         sync.Mutex
+	closed     bool
 }
 
 // NewSubprocessTransport creates a new subprocess transport.
 func NewSubprocessTransport(opts *Options) *SubprocessTransport {
 	return &SubprocessTransport{
-		opts:   opts,
-		msgCh:  make(chan json.RawMessage, 100),
-		errCh:  make(chan error, 10),
-		doneCh: make(chan struct{}),
+		opts:       opts,
+		msgCh:      make(chan json.RawMessage, 100),
+		errCh:      make(chan error, 10),
+		doneCh:     make(chan struct{}),
+		readDone:   make(chan struct{}),
+		stderrDone: make(chan struct{}),
 	}
 }
 
@@ -184,6 +189,10 @@ func (t *SubprocessTransport) buildArgs() []string {
 		args = append(args, "--resume", t.opts.ResumeConversation)
 	}
 
+	if t.opts.SessionID != "" {
+		args = append(args, "--session-id", t.opts.SessionID)
+	}
+
 	// Add MCP server configurations
 	for name, cfg := range t.opts.MCPServers {
 		args = append(args, "--mcp", formatMCPConfig(name, cfg))
@@ -211,6 +220,10 @@ func (t *SubprocessTransport) buildArgs() []string {
 		args = append(args, "--plugin", string(pluginJSON))
 	}
 
+	if len(t.opts.OutputSchema) > 0 {
+		args = append(args, "--json-schema", string(t.opts.OutputSchema))
+	}
+
 	// Include partial messages
 	if t.opts.IncludePartialMessages {
 		args = append(args, "--include-partial-messages")
@@ -248,6 +261,8 @@ func formatMCPConfig(name string, cfg MCPServerConfig) string {
 // Uses bufio.Reader instead of Scanner for lower latency - ReadSlice returns
 // data immediately when a newline is found, rather than waiting for more input.
 func (t *SubprocessTransport) readLoop() {
+	defer close(t.readDone)
+
 	reader := bufio.NewReaderSize(t.stdout, 64*1024) // 64KB buffer for faster reads
 	var accumulator []byte                           // For lines longer than buffer
 	msgCount := 0
@@ -339,9 +354,13 @@ func (t *SubprocessTransport) sendLine(line []byte) bool {
 }
 
 // readStderr reads error output.
+// The output is kept so it can be reported if the process fails. Output
+// alone is not an error, as the CLI also logs warnings on successful runs.
 func (t *SubprocessTransport) readStderr() {
+	defer close(t.stderrDone)
+
 	scanner := bufio.NewScanner(t.stderr)
-	var stderr strings.Builder
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -351,25 +370,24 @@ func (t *SubprocessTransport) readStderr() {
 			t.opts.StderrCallback(line)
 		}
 
-		stderr.WriteString(line)
-		stderr.WriteString("\n")
-	}
-
-	if stderr.Len() > 0 {
-		select {
-		case t.errCh <- &ProcessError{Stderr: stderr.String()}:
-		case <-t.doneCh:
-		}
+		t.stderrBuf.WriteString(line)
+		t.stderrBuf.WriteString("\n")
 	}
 }
 
 // waitLoop waits for the process to exit.
+// Both pipes are drained first, as required before calling Wait: Wait closes
+// them, which would lose the last lines of output and the stderr reported
+// with a non-zero exit status.
 func (t *SubprocessTransport) waitLoop() {
+	<-t.readDone
+	<-t.stderrDone
+
 	err := t.cmd.Wait()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			select {
-			case t.errCh <- &ProcessError{ExitCode: exitErr.ExitCode()}:
+			case t.errCh <- &ProcessError{ExitCode: exitErr.ExitCode(), Stderr: t.stderrBuf.String()}:
 			case <-t.doneCh:
 			}
 		}
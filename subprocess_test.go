@@ -0,0 +1,338 @@
+package clawde
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	fakeCLIOnce sync.Once
+	fakeCLIPath string
+	fakeCLIErr  error
+)
+
+// fakeCLI builds internal/fakeclaude once and returns the binary path.
+func fakeCLI(t *testing.T) string {
+	t.Helper()
+
+	fakeCLIOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "fakeclaude")
+		if err != nil {
+			fakeCLIErr = err
+			return
+		}
+		fakeCLIPath = filepath.Join(dir, "claude")
+		out, err := exec.Command("go", "build", "-o", fakeCLIPath, "./internal/fakeclaude").CombinedOutput()
+		if err != nil {
+			fakeCLIErr = errors.New(string(out))
+		}
+	})
+	if fakeCLIErr != nil {
+		t.Fatalf("build fakeclaude: %v", fakeCLIErr)
+	}
+	return fakeCLIPath
+}
+
+// scenarioFile writes a fakeclaude scenario and returns the option selecting it.
+func scenarioFile(t *testing.T, scenario string) Option {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(scenario), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return WithEnv(map[string]string{"FAKECLAUDE_SCENARIO": path})
+}
+
+func TestBuildArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: nil,
+		},
+		{
+			name: "basic flags",
+			opts: []Option{
+				WithSystemPrompt("be brief"),
+				WithModel("haiku"),
+				WithMaxTurns(3),
+				WithMaxBudget(1.5),
+				WithAllowedTools("Read", "Grep"),
+				WithPermissionMode(PermissionPlan),
+			},
+			want: []string{
+				"--system-prompt", "be brief",
+				"--model", "haiku",
+				"--max-turns", "3",
+				"--max-budget-usd", "1.50",
+				"--allowed-tools", "Read,Grep",
+				"--permission-mode", "plan",
+			},
+		},
+		{
+			name: "tools and presets",
+			opts: []Option{
+				WithSystemPromptPreset("claude_code", "extra"),
+				WithToolsList(),
+				WithIncludePartialMessages(true),
+			},
+			want: []string{
+				"--system-prompt", `{"type":"preset","preset":"claude_code","append":"extra"}`,
+				"--tools", "null",
+				"--include-partial-messages",
+			},
+		},
+		{
+			name: "session",
+			opts: []Option{WithSessionID("8f14e45f-ceea-4e7a-9b1c-2c6f3e1d0a11")},
+			want: []string{"--session-id", "8f14e45f-ceea-4e7a-9b1c-2c6f3e1d0a11"},
+		},
+		{
+			name: "output schema",
+			opts: []Option{WithOutputSchema(map[string]any{"type": "object"})},
+			want: []string{"--json-schema", `{"type":"object"}`},
+		},
+		{
+			name: "extra flag without value",
+			opts: []Option{WithExtraArg("debug-to-stderr", "")},
+			want: []string{"--debug-to-stderr"},
+		},
+	}
+
+	base := []string{"--output-format", "stream-json", "--verbose", "--input-format", "stream-json"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewSubprocessTransport(applyOptions(tt.opts)).buildArgs()
+			want := append(append([]string(nil), base...), tt.want...)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("buildArgs() =\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestFindCLI(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "no-such-claude")
+	_, err := NewSubprocessTransport(applyOptions([]Option{WithCLIPath(missing)})).findCLI()
+	if !errors.Is(err, ErrCLINotFound) {
+		t.Errorf("findCLI(missing) error = %v, want ErrCLINotFound", err)
+	}
+
+	path := fakeCLI(t)
+	got, err := NewSubprocessTransport(applyOptions([]Option{WithCLIPath(path)})).findCLI()
+	if err != nil || got != path {
+		t.Errorf("findCLI() = %q, %v, want %q", got, err, path)
+	}
+}
+
+func TestSubprocessEcho(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	text, err := QueryText(ctx, "hello", WithCLIPath(fakeCLI(t)))
+	if err != nil {
+		t.Fatalf("QueryText: %v", err)
+	}
+	if text != "echo: hello" {
+		t.Errorf("text = %q, want %q", text, "echo: hello")
+	}
+}
+
+func TestSubprocessScenarios(t *testing.T) {
+	cli := fakeCLI(t)
+
+	tests := []struct {
+		name     string
+		scenario string
+		opts     []Option
+		check    func(t *testing.T, msgs []Message, err error, stderr []string)
+	}{
+		{
+			name:     "slow start",
+			scenario: `{"start_delay_ms": 300, "steps": [{"action": "initialize"}, {"action": "reply", "text": "late"}]}`,
+			check: func(t *testing.T, msgs []Message, err error, stderr []string) {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				if len(msgs) != 2 {
+					t.Errorf("got %d messages, want 2", len(msgs))
+				}
+			},
+		},
+		{
+			name:     "args",
+			scenario: `{"steps": [{"action": "initialize"}, {"action": "prompt"}, {"action": "args"}, {"action": "stdout", "json": {"type": "result", "subtype": "success"}}]}`,
+			opts:     []Option{WithModel("haiku"), WithMaxTurns(2)},
+			check: func(t *testing.T, msgs []Message, err error, stderr []string) {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				sys, ok := msgs[0].(*SystemMessage)
+				if !ok || sys.Subtype != "args" {
+					t.Fatalf("first message = %#v, want args system message", msgs[0])
+				}
+			},
+		},
+		{
+			name:     "malformed json",
+			scenario: `{"steps": [{"action": "initialize"}, {"action": "prompt"}, {"action": "stdout", "line": "{not json"}]}`,
+			check: func(t *testing.T, msgs []Message, err error, stderr []string) {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) {
+					t.Fatalf("err = %v, want *ParseError", err)
+				}
+			},
+		},
+		{
+			name:     "huge line",
+			scenario: `{"steps": [{"action": "initialize"}, {"action": "prompt"}, {"action": "huge", "size": 1048576}, {"action": "stdout", "json": {"type": "result", "subtype": "success"}}]}`,
+			check: func(t *testing.T, msgs []Message, err error, stderr []string) {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				am, ok := msgs[0].(*AssistantMessage)
+				if !ok || len(am.Text()) != 1048576 {
+					t.Fatalf("first message = %T, want 1MiB assistant text", msgs[0])
+				}
+			},
+		},
+		{
+			name:     "non-zero exit",
+			scenario: `{"steps": [{"action": "initialize"}, {"action": "prompt"}, {"action": "stderr", "line": "out of credits"}, {"action": "exit", "code": 3}]}`,
+			check: func(t *testing.T, msgs []Message, err error, stderr []string) {
+				var procErr *ProcessError
+				if !errors.As(err, &procErr) {
+					t.Fatalf("err = %v, want *ProcessError", err)
+				}
+				if procErr.ExitCode != 3 || !strings.Contains(procErr.Stderr, "out of credits") {
+					t.Errorf("ProcessError = %+v, want code 3 with stderr", procErr)
+				}
+				if len(stderr) != 1 || stderr[0] != "out of credits" {
+					t.Errorf("stderr callback got %q", stderr)
+				}
+			},
+		},
+		{
+			name:     "crash",
+			scenario: `{"steps": [{"action": "initialize"}, {"action": "prompt"}, {"action": "crash"}]}`,
+			check: func(t *testing.T, msgs []Message, err error, stderr []string) {
+				var procErr *ProcessError
+				if !errors.As(err, &procErr) || procErr.ExitCode == 0 {
+					t.Fatalf("err = %v, want *ProcessError with non-zero exit", err)
+				}
+				if !strings.Contains(procErr.Stderr, "simulated crash") {
+					t.Errorf("stderr = %q, want panic message", procErr.Stderr)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var mu sync.Mutex
+			var stderr []string
+			opts := append([]Option{
+				WithCLIPath(cli),
+				scenarioFile(t, tt.scenario),
+				WithStderrCallback(func(line string) {
+					if strings.HasPrefix(line, "[clawde]") {
+						return
+					}
+					mu.Lock()
+					stderr = append(stderr, line)
+					mu.Unlock()
+				}),
+			}, tt.opts...)
+
+			stream, err := Query(ctx, "go", opts...)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			defer stream.Close()
+
+			msgs, err := stream.Collect()
+			mu.Lock()
+			defer mu.Unlock()
+			tt.check(t, msgs, err, stderr)
+		})
+	}
+}
+
+func TestSubprocessArgsReachCLI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tr := NewSubprocessTransport(applyOptions([]Option{
+		WithCLIPath(fakeCLI(t)),
+		WithModel("haiku"),
+		scenarioFile(t, `{"steps": [{"action": "args"}, {"action": "exit"}]}`),
+	}))
+	if err := tr.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer tr.Close()
+
+	raw, ok := <-tr.Messages()
+	if !ok {
+		t.Fatal("no message from fake CLI")
+	}
+	var msg struct {
+		Args []string `json:"args"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msg.Args, tr.buildArgs()) {
+		t.Errorf("CLI received %q, want %q", msg.Args, tr.buildArgs())
+	}
+
+	// A clean exit closes the message channel without reporting an error.
+	if _, ok := <-tr.Messages(); ok {
+		t.Error("expected message channel to close")
+	}
+	select {
+	case err := <-tr.Errors():
+		t.Errorf("unexpected error %v", err)
+	default:
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewClient(
+		WithCLIPath(fakeCLI(t)),
+		scenarioFile(t, `{"steps": [{"action": "sleep", "ms": 5000}]}`),
+		WithConnectTimeout(200*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	err = client.Connect(ctx)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Connect error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Connect took %v", elapsed)
+	}
+}
@@ -207,8 +207,9 @@ func (t *SubagentTracker) PostToolUseFailureHook(ctx context.Context, input *Hoo
 	toolCall.EndTime = time.Now()
 	toolCall.DurationMS = toolCall.EndTime.Sub(toolCall.StartTime).Milliseconds()
 	toolCall.Success = false
+	toolCall.Error = input.Error
 
-	if input.ToolResult != nil {
+	if toolCall.Error == "" && input.ToolResult != nil {
 		if errMsg, ok := input.ToolResult["error"].(string); ok {
 			toolCall.Error = errMsg
 		}
@@ -11,6 +11,7 @@ type Transport interface {
 	Start(ctx context.Context) error
 
 	// Write sends data to the transport.
+	// It may be called from multiple goroutines.
 	Write(data []byte) error
 
 	// Messages returns a channel of incoming JSON messages.
@@ -22,3 +23,30 @@ type Transport interface {
 	// Close shuts down the transport.
 	Close() error
 }
+
+// TransportFactory creates a Transport for the given options.
+// It is called once per Connect.
+type TransportFactory func(opts *Options) (Transport, error)
+
+// newTransport returns the transport configured in opts, falling back to
+// a SubprocessTransport running the Claude CLI. The transport is wrapped in
+// a RecordingTransport when a recorder is configured.
+func newTransport(opts *Options) (Transport, error) {
+	var t Transport
+	switch {
+	case opts.TransportFactory != nil:
+		var err error
+		if t, err = opts.TransportFactory(opts); err != nil {
+			return nil, err
+		}
+	case opts.Transport != nil:
+		t = opts.Transport
+	default:
+		t = NewSubprocessTransport(opts)
+	}
+
+	if opts.Recorder != nil {
+		t = NewRecordingTransport(t, opts.Recorder)
+	}
+	return t, nil
+}
@@ -0,0 +1,335 @@
+package clawde
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// errTimedOutTurn fails turns queued behind a turn that timed out without a
+// result, as the CLI answers them only once that turn has ended.
+var errTimedOutTurn = fmt.Errorf("%w: an earlier turn timed out and has not ended", ErrTimeout)
+
+// turn is one prompt and the messages answering it, up to and including the
+// result message. A client's turns are answered by the CLI in order.
+type turn struct {
+	msgCh chan Message
+	errCh chan error
+
+	claimed     bool // a Stream or Receive reads this turn
+	abandonCh   chan struct{}
+	abandonOnce sync.Once
+
+	activeCh  chan struct{} // closed once the CLI answers this turn
+	activated bool
+	timedOut  bool // given up on after its timeout
+}
+
+func newTurn() *turn {
+	return &turn{
+		msgCh:     make(chan Message, 16),
+		errCh:     make(chan error, 8),
+		abandonCh: make(chan struct{}),
+		activeCh:  make(chan struct{}),
+	}
+}
+
+// abandon discards the rest of the turn, e.g. when its Stream is closed early.
+func (t *turn) abandon() {
+	t.abandonOnce.Do(func() { close(t.abandonCh) })
+}
+
+// turnQueue routes messages from a QueryHandler to the turn they belong to.
+type turnQueue struct {
+	mu      sync.Mutex
+	turns   []*turn // turns awaiting a result, oldest first
+	unread  []*turn // finished turns nobody has claimed yet, oldest first
+	lateErr error   // error reported while no turn was active
+	closed  bool    // the connection has ended
+	cause   error   // why the connection ended, reported to cut-off turns
+	logf    func(format string, args ...any)
+
+	recvCh   chan Message  // messages of unread turns, see Client.Receive
+	recvWake chan struct{} // signals a new unread turn or the end of the connection
+}
+
+func newTurnQueue(opts *Options) *turnQueue {
+	return &turnQueue{
+		logf: func(format string, args ...any) {
+			if opts.StderrCallback != nil {
+				opts.StderrCallback(fmt.Sprintf("[clawde] "+format, args...))
+			}
+		},
+	}
+}
+
+// enqueue adds a turn for a prompt about to be sent. It fails once the
+// connection has ended, as nothing could answer the prompt.
+func (tq *turnQueue) enqueue(claimed bool) (*turn, error) {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	if tq.closed {
+		return nil, tq.cause
+	}
+	for _, other := range tq.turns {
+		if other.timedOut {
+			return nil, errTimedOutTurn
+		}
+	}
+	t := newTurn()
+	t.claimed = claimed
+	if tq.lateErr != nil {
+		t.errCh <- tq.lateErr
+		tq.lateErr = nil
+	}
+	tq.turns = append(tq.turns, t)
+	tq.activateHead()
+	if !claimed {
+		tq.wakeReceiver()
+	}
+	return t, nil
+}
+
+// activateHead marks the oldest pending turn as the one the CLI answers.
+// Must be called with tq.mu held.
+func (tq *turnQueue) activateHead() {
+	if len(tq.turns) > 0 && !tq.turns[0].activated {
+		tq.turns[0].activated = true
+		close(tq.turns[0].activeCh)
+	}
+}
+
+// giveUp abandons t once its timeout and grace period have expired, and
+// fails the turns queued behind it. The CLI still answers their prompts
+// after t ends, so they stay queued with their messages discarded. No new
+// turns are accepted until t has ended.
+func (tq *turnQueue) giveUp(t *turn) {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	if !slices.Contains(tq.turns, t) {
+		return
+	}
+	t.timedOut = true
+	t.abandon()
+	for _, other := range tq.turns {
+		if other == t {
+			continue
+		}
+		select {
+		case other.errCh <- errTimedOutTurn:
+		default:
+		}
+		other.abandon()
+	}
+}
+
+// receive returns the channel handed out by Client.Receive. From the first
+// call on, every turn nobody reads is forwarded to it in order. The channel
+// closes once the connection has ended and the last turn is forwarded, or
+// when done is closed.
+func (tq *turnQueue) receive(done <-chan struct{}) <-chan Message {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	if tq.recvCh == nil {
+		tq.recvCh = make(chan Message)
+		tq.recvWake = make(chan struct{}, 1)
+		go tq.forwardUnread(done)
+	}
+	return tq.recvCh
+}
+
+// forwardUnread claims unread turns and copies their messages to recvCh.
+func (tq *turnQueue) forwardUnread(done <-chan struct{}) {
+	defer close(tq.recvCh)
+
+	for {
+		t, ok := tq.claim()
+		if !ok {
+			tq.mu.Lock()
+			closed := tq.closed
+			tq.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-tq.recvWake:
+			case <-done:
+				return
+			}
+			continue
+		}
+		for msg := range t.msgCh {
+			select {
+			case tq.recvCh <- msg:
+			case <-done:
+				t.abandon()
+				return
+			}
+		}
+	}
+}
+
+// wakeReceiver tells forwardUnread to look for turns again. Must be called
+// with tq.mu held.
+func (tq *turnQueue) wakeReceiver() {
+	if tq.recvWake == nil {
+		return
+	}
+	select {
+	case tq.recvWake <- struct{}{}:
+	default:
+	}
+}
+
+// remove drops a turn whose prompt could not be sent.
+func (tq *turnQueue) remove(t *turn) {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	for i, other := range tq.turns {
+		if other == t {
+			tq.turns = append(tq.turns[:i], tq.turns[i+1:]...)
+			tq.activateHead()
+			return
+		}
+	}
+}
+
+// claim returns the oldest turn nobody reads yet, or false when every
+// pending turn is already claimed.
+func (tq *turnQueue) claim() (*turn, bool) {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	if len(tq.unread) > 0 {
+		t := tq.unread[0]
+		tq.unread = tq.unread[1:]
+		t.claimed = true
+		return t, true
+	}
+	for _, t := range tq.turns {
+		if !t.claimed {
+			t.claimed = true
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// active returns the turn currently being answered.
+func (tq *turnQueue) active() *turn {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	if len(tq.turns) == 0 {
+		return nil
+	}
+	return tq.turns[0]
+}
+
+// route delivers messages and errors from q until its message channel closes.
+func (tq *turnQueue) route(q *QueryHandler) {
+	msgCh, errCh := q.Messages(), q.Errors()
+	for {
+		select {
+		case msg, ok := <-msgCh:
+			if !ok {
+				// Deliver errors reported just before the channel closed,
+				// such as the exit status of the CLI.
+				var cause error
+			drain:
+				for {
+					select {
+					case err := <-errCh:
+						tq.deliverError(err)
+						if err != nil {
+							cause = err
+						}
+					default:
+						break drain
+					}
+				}
+				tq.close(cause)
+				return
+			}
+			tq.deliverMessage(msg)
+
+		case err := <-errCh:
+			tq.deliverError(err)
+		}
+	}
+}
+
+// deliverMessage forwards msg to the active turn, ending it on a result.
+func (tq *turnQueue) deliverMessage(msg Message) {
+	t := tq.active()
+	if t == nil {
+		tq.logf("turns: dropping %T outside of a turn", msg)
+		return
+	}
+
+	// A turn nobody reads yet holds up later turns once its buffer is
+	// full, as its messages must not be lost.
+	select {
+	case t.msgCh <- msg:
+	case <-t.abandonCh:
+	}
+
+	if _, ok := msg.(*ResultMessage); ok {
+		tq.mu.Lock()
+		tq.turns = tq.turns[1:]
+		tq.activateHead()
+		if !t.claimed && !t.timedOut {
+			tq.unread = append(tq.unread, t)
+		}
+		tq.mu.Unlock()
+		close(t.msgCh)
+	}
+}
+
+// deliverError forwards err to the active turn, or keeps it for the next one.
+func (tq *turnQueue) deliverError(err error) {
+	if err == nil {
+		return
+	}
+	t := tq.active()
+	if t == nil {
+		tq.mu.Lock()
+		if tq.lateErr == nil {
+			tq.lateErr = err
+		}
+		tq.mu.Unlock()
+		return
+	}
+	select {
+	case t.errCh <- err:
+	default:
+		tq.logf("turns: dropping error %v", err)
+	}
+}
+
+// close ends every pending turn once the connection is gone. The turns
+// fail with cause, or ErrStreamClosed if the connection ended without error.
+func (tq *turnQueue) close(cause error) {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	if cause == nil {
+		cause = ErrStreamClosed
+	}
+	tq.closed = true
+	tq.cause = cause
+	for _, t := range tq.turns {
+		select {
+		case t.errCh <- cause:
+		default:
+			// The turn already has errors queued to report.
+		}
+		close(t.msgCh)
+	}
+	tq.turns = nil
+	tq.wakeReceiver()
+}
@@ -0,0 +1,203 @@
+package clawde_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nexo-tech/clawde"
+	"github.com/nexo-tech/clawde/clawdetest"
+)
+
+func TestStreamsYieldOnlyTheirTurn(t *testing.T) {
+	ft := clawdetest.NewTransport(
+		clawdetest.WaitForPrompt(),
+		clawdetest.AssistantText("one-a"),
+		clawdetest.AssistantText("one-b"),
+		clawdetest.Result("one"),
+		clawdetest.Reply("two"),
+		clawdetest.Reply("three"),
+	)
+	ctx, client := connect(t, ft)
+
+	// Abandon the first turn after its first message.
+	first, err := client.Query(ctx, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Next() {
+		t.Fatalf("first stream ended early: %v", first.Err())
+	}
+	first.Close()
+
+	// Issue two queries before reading either; read the later one first.
+	second, err := client.Query(ctx, "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := client.Query(ctx, "three")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := third.CollectText()
+	if err != nil || text != "three" {
+		t.Errorf("third stream = %q, %v; want three", text, err)
+	}
+	text, err = second.CollectText()
+	if err != nil || text != "two" {
+		t.Errorf("second stream = %q, %v; want two", text, err)
+	}
+	if err := ft.Err(); err != nil {
+		t.Fatalf("script: %v", err)
+	}
+}
+
+func TestReceive(t *testing.T) {
+	unread := []clawdetest.Step{clawdetest.WaitForPrompt()}
+	for i := 0; i < 40; i++ {
+		unread = append(unread, clawdetest.AssistantText("chatter"))
+	}
+	unread = append(unread, clawdetest.Result("chatter"))
+	ft := clawdetest.NewTransport(append(unread, clawdetest.Reply("answer"), clawdetest.Reply("later"))...)
+	ctx, client := connect(t, ft)
+
+	// A query sent behind an unread prompt waits for it instead of the
+	// prompt's response being dropped.
+	if err := client.Send(ctx, "chat"); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.Query(ctx, "question")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := client.Receive(ctx)
+	var messages int
+	for msg := range received {
+		messages++
+		if _, ok := msg.(*clawde.ResultMessage); ok {
+			break
+		}
+	}
+	if messages != 41 {
+		t.Errorf("Receive yielded %d messages, want 41", messages)
+	}
+	text, err := stream.CollectText()
+	if err != nil || text != "answer" {
+		t.Errorf("stream = %q, %v; want answer", text, err)
+	}
+
+	// Receive keeps yielding the responses to later prompts.
+	if err := client.Send(ctx, "more"); err != nil {
+		t.Fatal(err)
+	}
+	var later string
+	for msg := range received {
+		if am, ok := msg.(*clawde.AssistantMessage); ok {
+			later = am.Text()
+		}
+		if _, ok := msg.(*clawde.ResultMessage); ok {
+			break
+		}
+	}
+	if later != "later" {
+		t.Errorf("second response = %q, want later", later)
+	}
+
+	client.Close()
+	if _, ok := <-received; ok {
+		t.Error("Receive channel still open after Close")
+	}
+}
+
+func TestReceiveTurn(t *testing.T) {
+	ft := clawdetest.NewTransport(clawdetest.Reply("one"), clawdetest.Reply("two"))
+	ctx, client := connect(t, ft)
+
+	if _, err := client.ReceiveTurn(ctx); !errors.Is(err, clawde.ErrNoPendingPrompt) {
+		t.Errorf("ReceiveTurn without Send: err = %v, want ErrNoPendingPrompt", err)
+	}
+	for _, prompt := range []string{"one", "two"} {
+		if err := client.Send(ctx, prompt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"one", "two"} {
+		stream, err := client.ReceiveTurn(ctx)
+		if err != nil {
+			t.Fatalf("ReceiveTurn for %s: %v", want, err)
+		}
+		if text, err := stream.CollectText(); err != nil || text != want {
+			t.Errorf("stream = %q, %v; want %s", text, err, want)
+		}
+	}
+}
+
+func TestCutOffTurnsReportError(t *testing.T) {
+	t.Run("cli exits", func(t *testing.T) {
+		ft := clawdetest.NewTransport(
+			clawdetest.WaitForPrompt(),
+			clawdetest.AssistantText("partial"),
+			clawdetest.Exit(),
+		)
+		ctx, client := connect(t, ft)
+
+		first, err := client.Query(ctx, "one")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := client.Query(ctx, "two")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, stream := range map[string]*clawde.Stream{"first": first, "second": second} {
+			if err := stream.Wait(); !errors.Is(err, clawde.ErrStreamClosed) {
+				t.Errorf("%s stream: err = %v, want ErrStreamClosed", name, err)
+			}
+			if stream.Result() != nil {
+				t.Errorf("%s stream has a result", name)
+			}
+		}
+
+		if _, err := client.Query(ctx, "three"); !errors.Is(err, clawde.ErrStreamClosed) {
+			t.Errorf("Query after the CLI exited: err = %v, want ErrStreamClosed", err)
+		}
+	})
+
+	t.Run("query stream", func(t *testing.T) {
+		ft := clawdetest.NewTransport(
+			clawdetest.Reply("one"),
+			clawdetest.WaitForPrompt(),
+			clawdetest.Exit(),
+		)
+		ctx, client := connect(t, ft)
+
+		inputs := make(chan clawde.UserInput, 2)
+		inputs <- clawde.UserInput{Text: "one"}
+		inputs <- clawde.UserInput{Text: "two"}
+		stream, err := client.QueryStream(ctx, inputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Wait(); !errors.Is(err, clawde.ErrStreamClosed) {
+			t.Errorf("err = %v, want ErrStreamClosed", err)
+		}
+	})
+
+	t.Run("client closed", func(t *testing.T) {
+		ft := clawdetest.NewTransport(
+			clawdetest.WaitForPrompt(),
+			clawdetest.Sleep(time.Minute),
+		)
+		ctx, client := connect(t, ft)
+
+		stream, err := client.Query(ctx, "one")
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.Close()
+		if err := stream.Wait(); !errors.Is(err, clawde.ErrStreamClosed) {
+			t.Errorf("err = %v, want ErrStreamClosed", err)
+		}
+	})
+}
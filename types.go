@@ -34,9 +34,12 @@ func (m *UserMessage) Text() string {
 
 // AssistantMessage represents a message from Claude.
 type AssistantMessage struct {
+	ID              string         `json:"id,omitempty"`
 	Role            string         `json:"role"`
 	Content         []ContentBlock `json:"content"`
 	Model           string         `json:"model,omitempty"`
+	StopReason      string         `json:"stop_reason,omitempty"`
+	Usage           *Usage         `json:"usage,omitempty"`
 	ParentToolUseID *string        `json:"parent_tool_use_id,omitempty"`
 	Error           *string        `json:"error,omitempty"`
 }
@@ -78,35 +81,157 @@ func (m *AssistantMessage) ToolUses() []*ToolUseBlock {
 
 // SystemMessage represents a system message.
 type SystemMessage struct {
-	Type    string `json:"type"`
-	Subtype string `json:"subtype,omitempty"`
-	Message string `json:"message,omitempty"`
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype,omitempty"`
+	Message   string `json:"message,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+
+	// Data is the raw message, including fields not decoded above.
+	Data json.RawMessage `json:"-"`
+
+	// Init holds the session metadata of an "init" system message.
+	Init *InitMessage `json:"-"`
 }
 
 func (SystemMessage) isMessage() {}
 
+// InitMessage is the session metadata reported by the CLI in the "init"
+// system message and the initialize control response.
+type InitMessage struct {
+	SessionID         string            `json:"session_id,omitempty"`
+	Cwd               string            `json:"cwd,omitempty"`
+	Model             string            `json:"model,omitempty"`
+	PermissionMode    PermissionMode    `json:"permissionMode,omitempty"`
+	APIKeySource      string            `json:"apiKeySource,omitempty"`
+	ClaudeCodeVersion string            `json:"claude_code_version,omitempty"`
+	Tools             []string          `json:"tools,omitempty"`
+	MCPServers        []MCPServerStatus `json:"mcp_servers,omitempty"`
+	SlashCommands     []string          `json:"slash_commands,omitempty"`
+	Commands          []SlashCommand    `json:"commands,omitempty"`
+	Agents            []string          `json:"agents,omitempty"`
+	Skills            []string          `json:"skills,omitempty"`
+	Plugins           []PluginInfo      `json:"plugins,omitempty"`
+	OutputStyle       string            `json:"output_style,omitempty"`
+}
+
+// MCPServerStatus is the connection status of an MCP server.
+type MCPServerStatus struct {
+	Name string `json:"name"`
+	// Status is "connected", "failed", "pending" or "needs-auth".
+	Status string `json:"status"`
+}
+
+// SlashCommand describes a slash command available in the session.
+type SlashCommand struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	ArgumentHint string `json:"argumentHint,omitempty"`
+}
+
+// PluginInfo describes a plugin loaded by the CLI.
+type PluginInfo struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+}
+
+// HasTool reports whether the named tool is available to the agent.
+func (m *InitMessage) HasTool(name string) bool {
+	for _, t := range m.Tools {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MCPServer returns the status of the named MCP server.
+func (m *InitMessage) MCPServer(name string) (*MCPServerStatus, bool) {
+	for i := range m.MCPServers {
+		if m.MCPServers[i].Name == name {
+			return &m.MCPServers[i], true
+		}
+	}
+	return nil, false
+}
+
 // ResultMessage represents the final result of a query.
 type ResultMessage struct {
-	Type         string  `json:"type"`
-	Subtype      string  `json:"subtype,omitempty"`
-	DurationMS   int64   `json:"duration_ms,omitempty"`
-	DurationAPI  int64   `json:"duration_api_ms,omitempty"`
-	NumTurns     int     `json:"num_turns,omitempty"`
-	CostUSD      float64 `json:"cost_usd,omitempty"`
-	IsError      bool    `json:"is_error,omitempty"`
-	SessionID    string  `json:"session_id,omitempty"`
-	TotalCostUSD float64 `json:"total_cost_usd,omitempty"`
+	Type              string                `json:"type"`
+	Subtype           string                `json:"subtype,omitempty"`
+	DurationMS        int64                 `json:"duration_ms,omitempty"`
+	DurationAPI       int64                 `json:"duration_api_ms,omitempty"`
+	NumTurns          int                   `json:"num_turns,omitempty"`
+	CostUSD           float64               `json:"cost_usd,omitempty"`
+	IsError           bool                  `json:"is_error,omitempty"`
+	SessionID         string                `json:"session_id,omitempty"`
+	TotalCostUSD      float64               `json:"total_cost_usd,omitempty"`
+	Result            string                `json:"result,omitempty"`
+	Usage             *Usage                `json:"usage,omitempty"`
+	ModelUsage        map[string]ModelUsage `json:"modelUsage,omitempty"`
+	PermissionDenials []PermissionDenial    `json:"permission_denials,omitempty"`
+	StructuredOutput  json.RawMessage       `json:"structured_output,omitempty"`
+
+	// interrupted is set when the turn ended after Client.Interrupt.
+	interrupted bool
 }
 
 func (ResultMessage) isMessage() {}
 
+// Usage holds the token counts of an API message or a whole query.
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// Add adds the token counts of other to u.
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+}
+
+// TotalTokens returns the sum of all token counts.
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// ModelUsage holds the per-model totals reported in a result message.
+type ModelUsage struct {
+	InputTokens              int     `json:"inputTokens"`
+	OutputTokens             int     `json:"outputTokens"`
+	CacheReadInputTokens     int     `json:"cacheReadInputTokens,omitempty"`
+	CacheCreationInputTokens int     `json:"cacheCreationInputTokens,omitempty"`
+	WebSearchRequests        int     `json:"webSearchRequests,omitempty"`
+	CostUSD                  float64 `json:"costUSD,omitempty"`
+	ContextWindow            int     `json:"contextWindow,omitempty"`
+}
+
+// PermissionDenial records a tool use that was denied during the query.
+type PermissionDenial struct {
+	ToolName  string          `json:"tool_name"`
+	ToolUseID string          `json:"tool_use_id"`
+	ToolInput json.RawMessage `json:"tool_input,omitempty"`
+}
+
 // StreamEvent represents a streaming event during message generation.
+// It is emitted when partial messages are enabled.
 type StreamEvent struct {
-	Type      string          `json:"type"`
-	Subtype   string          `json:"subtype,omitempty"`
-	SessionID string          `json:"session_id,omitempty"`
-	Index     int             `json:"index,omitempty"`
-	Delta     json.RawMessage `json:"delta,omitempty"`
+	Type            string          `json:"type"`
+	Subtype         string          `json:"subtype,omitempty"`
+	UUID            string          `json:"uuid,omitempty"`
+	SessionID       string          `json:"session_id,omitempty"`
+	ParentToolUseID *string         `json:"parent_tool_use_id,omitempty"`
+	Index           int             `json:"index,omitempty"`
+	Delta           json.RawMessage `json:"delta,omitempty"`
+
+	// Event is the raw API streaming event.
+	Event json.RawMessage `json:"event,omitempty"`
+
+	// Data is the decoded API event, or nil for unknown event types.
+	Data StreamEventData `json:"-"`
 }
 
 func (StreamEvent) isMessage() {}
@@ -0,0 +1,464 @@
+package clawde
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// validateJSON checks data against a JSON Schema. It returns a
+// *ValidationError listing every violation, or nil if data matches.
+// Keywords the validator does not implement, such as if/then/else, are
+// ignored: data is only checked against the rest of the schema.
+func validateJSON(schema, data json.RawMessage) error {
+	cs, err := compileSchema(schema)
+	if err != nil {
+		return err
+	}
+	return cs.validate(data)
+}
+
+// maxRefDepth bounds how many $refs are followed without descending into
+// the value, so a schema referring to itself cannot loop forever.
+const maxRefDepth = 32
+
+// schemaMapKeywords hold a map of names to subschemas.
+var schemaMapKeywords = map[string]bool{
+	"properties": true, "patternProperties": true, "dependentSchemas": true,
+	"$defs": true, "definitions": true,
+}
+
+// dataKeywords hold values or names rather than subschemas.
+var dataKeywords = map[string]bool{
+	"const": true, "enum": true, "default": true, "examples": true,
+	"required": true, "dependentRequired": true, "type": true,
+}
+
+// compiledSchema is a decoded JSON Schema with its patterns compiled. It is
+// not modified after compileSchema, so it can be used concurrently.
+type compiledSchema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+// compileSchema decodes a JSON Schema and compiles its patterns.
+func compileSchema(schema json.RawMessage) (*compiledSchema, error) {
+	cs, err := decodeSchema(schema)
+	if err != nil {
+		return nil, fmt.Errorf("clawde: invalid schema: %w", err)
+	}
+	return cs, nil
+}
+
+// decodeSchema is compileSchema without the error prefix, for callers that
+// say which schema is invalid.
+func decodeSchema(schema json.RawMessage) (*compiledSchema, error) {
+	cs := &compiledSchema{patterns: make(map[string]*regexp.Regexp)}
+	if err := json.Unmarshal(schema, &cs.root); err != nil {
+		return nil, err
+	}
+	if err := cs.compile(cs.root, "#"); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// compile compiles the patterns of schema, located at pointer, and of every
+// subschema in it, including those under keywords the validator ignores.
+func (cs *compiledSchema) compile(schema any, pointer string) error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: bad pattern: %w", pointer, err)
+		}
+		cs.patterns[pattern] = re
+	}
+
+	for k, v := range s {
+		switch {
+		case dataKeywords[k]:
+		case schemaMapKeywords[k]:
+			m, _ := v.(map[string]any)
+			for name, sub := range m {
+				if err := cs.compile(sub, pointer+"/"+k+"/"+name); err != nil {
+					return err
+				}
+			}
+		default:
+			if list, ok := v.([]any); ok {
+				for i, sub := range list {
+					if err := cs.compile(sub, fmt.Sprintf("%s/%s/%d", pointer, k, i)); err != nil {
+						return err
+					}
+				}
+			} else if err := cs.compile(v, pointer+"/"+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pattern returns the compiled form of a pattern.
+func (cs *compiledSchema) pattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := cs.patterns[pattern]; ok {
+		return re, nil
+	}
+	// Not reached by compile, e.g. a pattern inside a $ref target that is
+	// not a subschema. Compile it without caching to keep cs read-only.
+	return regexp.Compile(pattern)
+}
+
+// validate checks data against the schema.
+func (cs *compiledSchema) validate(data json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return &ValidationError{Violations: []SchemaViolation{{Path: "$", Message: fmt.Sprintf("invalid JSON: %v", err)}}}
+	}
+
+	sv := &schemaValidator{schema: cs}
+	sv.validate(cs.root, v, "$", 0)
+	if len(sv.violations) > 0 {
+		return &ValidationError{Violations: sv.violations}
+	}
+	return nil
+}
+
+// schemaValidator collects the violations of a value against a schema.
+type schemaValidator struct {
+	schema     *compiledSchema
+	violations []SchemaViolation
+}
+
+// matches reports whether v matches schema, without recording violations.
+func (sv *schemaValidator) matches(schema, v any, path string, refs int) bool {
+	sub := &schemaValidator{schema: sv.schema}
+	sub.validate(schema, v, path, refs)
+	return len(sub.violations) == 0
+}
+
+// validate records the ways v at path does not match schema. refs counts
+// the $refs followed since the last descent into v.
+func (sv *schemaValidator) validate(schema, v any, path string, refs int) {
+	fail := func(format string, args ...any) {
+		sv.violations = append(sv.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	s, ok := schema.(map[string]any)
+	if !ok {
+		if schema == false {
+			fail("value is not allowed")
+		}
+		return
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		target, ok := sv.resolve(ref)
+		if !ok || refs >= maxRefDepth {
+			fail("cannot resolve schema reference %q", ref)
+			return
+		}
+		sv.validate(target, v, path, refs+1)
+	}
+
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			sv.validate(sub, v, path, refs)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if sv.matches(sub, v, path, refs) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("value must match at least one of the anyOf schemas")
+		}
+	}
+	if one, ok := s["oneOf"].([]any); ok {
+		n := 0
+		for _, sub := range one {
+			if sv.matches(sub, v, path, refs) {
+				n++
+			}
+		}
+		if n != 1 {
+			fail("value must match exactly one of the oneOf schemas, matched %d", n)
+		}
+	}
+	if not, ok := s["not"]; ok && sv.matches(not, v, path, refs) {
+		fail("value must not match the not schema")
+	}
+
+	if types := schemaTypes(s["type"]); len(types) > 0 {
+		got := jsonType(v)
+		matched := false
+		for _, t := range types {
+			if t == got || (t == "number" && got == "integer") {
+				matched = true
+			}
+		}
+		if !matched {
+			fail("expected %s, got %s", strings.Join(types, " or "), got)
+			return
+		}
+	}
+
+	if enum, ok := s["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(normalizeJSON(e), normalizeJSON(v)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed, _ := json.Marshal(enum)
+			fail("value must be one of %s", allowed)
+		}
+	}
+
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(normalizeJSON(c), normalizeJSON(v)) {
+		want, _ := json.Marshal(c)
+		fail("value must be %s", want)
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := float64(utf8.RuneCountInString(v))
+		if min, ok := s["minLength"].(float64); ok && n < min {
+			fail("length must be at least %v", min)
+		}
+		if max, ok := s["maxLength"].(float64); ok && n > max {
+			fail("length must be at most %v", max)
+		}
+		if pattern, ok := s["pattern"].(string); ok {
+			if re, err := sv.schema.pattern(pattern); err != nil {
+				fail("schema has a bad pattern: %v", err)
+			} else if !re.MatchString(v) {
+				fail("value must match pattern %q", pattern)
+			}
+		}
+		if format, ok := s["format"].(string); ok && !matchesFormat(format, v) {
+			fail("value must be a valid %s", format)
+		}
+
+	case json.Number:
+		n, _ := v.Float64()
+		if min, ok := s["minimum"].(float64); ok && n < min {
+			fail("value must be at least %v", min)
+		}
+		if max, ok := s["maximum"].(float64); ok && n > max {
+			fail("value must be at most %v", max)
+		}
+		if min, ok := s["exclusiveMinimum"].(float64); ok && n <= min {
+			fail("value must be greater than %v", min)
+		}
+		if max, ok := s["exclusiveMaximum"].(float64); ok && n >= max {
+			fail("value must be less than %v", max)
+		}
+		if m, ok := s["multipleOf"].(float64); ok && m > 0 {
+			if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("value must be a multiple of %v", m)
+			}
+		}
+
+	case map[string]any:
+		n := float64(len(v))
+		if min, ok := s["minProperties"].(float64); ok && n < min {
+			fail("must have at least %v properties", min)
+		}
+		if max, ok := s["maxProperties"].(float64); ok && n > max {
+			fail("must have at most %v properties", max)
+		}
+		props, _ := s["properties"].(map[string]any)
+		if required, ok := s["required"].([]any); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, ok := v[name]; !ok {
+					fail("missing required property %q", name)
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := path + "." + k
+			if ps, ok := props[k]; ok {
+				sv.validate(ps, v[k], child, 0)
+				continue
+			}
+			switch extra := s["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					sv.violations = append(sv.violations, SchemaViolation{Path: child, Message: "unexpected property"})
+				}
+			case map[string]any:
+				sv.validate(extra, v[k], child, 0)
+			}
+		}
+
+	case []any:
+		n := float64(len(v))
+		if min, ok := s["minItems"].(float64); ok && n < min {
+			fail("must have at least %v items", min)
+		}
+		if max, ok := s["maxItems"].(float64); ok && n > max {
+			fail("must have at most %v items", max)
+		}
+		if unique, _ := s["uniqueItems"].(bool); unique {
+			seen := make(map[string]bool, len(v))
+			for _, item := range v {
+				key, _ := json.Marshal(normalizeJSON(item))
+				if seen[string(key)] {
+					fail("items must be unique, %s is repeated", key)
+					break
+				}
+				seen[string(key)] = true
+			}
+		}
+		if items, ok := s["items"]; ok {
+			for i, item := range v {
+				sv.validate(items, item, fmt.Sprintf("%s[%d]", path, i), 0)
+			}
+		}
+	}
+}
+
+// uuidPattern matches the textual form of a UUID.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// matchesFormat reports whether s is valid for a "format" keyword.
+// Unknown formats always match.
+func matchesFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	}
+	return true
+}
+
+// resolve returns the schema a local reference such as "#/$defs/Node"
+// points to.
+func (sv *schemaValidator) resolve(ref string) (any, bool) {
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, false
+	}
+	target := sv.schema.root
+	for _, token := range strings.Split(ref, "/")[1:] {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		m, ok := target.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if target, ok = m[token]; !ok {
+			return nil, false
+		}
+	}
+	return target, true
+}
+
+// schemaTypes returns the types allowed by a schema's "type" keyword.
+func schemaTypes(t any) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, s := range t {
+			if s, ok := s.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonType returns the JSON Schema type of a decoded value.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := v.Float64(); err == nil && f == float64(int64(f)) {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// normalizeJSON converts numbers in a decoded value to float64 so values
+// decoded with and without UseNumber compare equal.
+func normalizeJSON(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = normalizeJSON(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = normalizeJSON(e)
+		}
+		return out
+	}
+	return v
+}
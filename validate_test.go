@@ -0,0 +1,131 @@
+package clawde
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidateCombinators(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"id":    {"anyOf": [{"type": "integer"}, {"type": "string", "pattern": "^[a-z]+$"}]},
+			"shape": {"oneOf": [{"const": "circle"}, {"enum": ["circle", "square"]}]},
+			"size":  {"allOf": [{"minimum": 1}, {"maximum": 10}]},
+			"name":  {"not": {"const": "admin"}}
+		}
+	}`)
+
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{`{"id": 3, "shape": "square", "size": 5, "name": "bob"}`, nil},
+		{`{"id": "abc"}`, nil},
+		{`{"id": "ABC"}`, []string{"$.id: value must match at least one of the anyOf schemas"}},
+		{`{"shape": "circle"}`, []string{"$.shape: value must match exactly one of the oneOf schemas, matched 2"}},
+		{`{"shape": "oval"}`, []string{"$.shape: value must match exactly one of the oneOf schemas, matched 0"}},
+		{`{"size": 0}`, []string{"$.size: value must be at least 1"}},
+		{`{"size": 11}`, []string{"$.size: value must be at most 10"}},
+		{`{"name": "admin"}`, []string{"$.name: value must not match the not schema"}},
+	}
+	for _, tt := range tests {
+		err := validateJSON(schema, json.RawMessage(tt.data))
+		var got []string
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			for _, v := range verr.Violations {
+				got = append(got, v.String())
+			}
+		} else if err != nil {
+			t.Errorf("validate(%s): %v", tt.data, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("validate(%s) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRejectsBadSchemas(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{`{"properties": {"a": {"type": "string", "pattern": "("}}}`, `#/properties/a: bad pattern`},
+		{`{"if": {"pattern": "["}, "then": {"minLength": 1}}`, `#/if: bad pattern`},
+		{`{"prefixItems": [{"type": "string"}, {"pattern": "a{2,1}"}]}`, `#/prefixItems/1: bad pattern`},
+	}
+	for _, tt := range tests {
+		err := validateJSON(json.RawMessage(tt.schema), json.RawMessage(`{}`))
+		var verr *ValidationError
+		if err == nil || errors.As(err, &verr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("validate against %s = %v, want schema error containing %q", tt.schema, err, tt.want)
+		}
+	}
+}
+
+func TestValidateKeywords(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"minProperties": 1,
+		"maxProperties": 2,
+		"properties": {
+			"even":  {"type": "integer", "multipleOf": 2},
+			"price": {"type": "number", "multipleOf": 0.01}
+		},
+		"if": {"required": ["even"]},
+		"then": {"required": ["price"]},
+		"patternProperties": {"^x-": {"type": "string"}}
+	}`)
+
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{`{"even": 4, "price": 0.3}`, nil},
+		// if/then and patternProperties are not implemented and ignored.
+		{`{"even": 4}`, nil},
+		{`{"even": 3}`, []string{"$.even: value must be a multiple of 2"}},
+		{`{"price": 0.125}`, []string{"$.price: value must be a multiple of 0.01"}},
+		{`{}`, []string{"$: must have at least 1 properties"}},
+		{`{"even": 2, "price": 1, "x-id": "a"}`, []string{"$: must have at most 2 properties"}},
+	}
+	for _, tt := range tests {
+		err := validateJSON(schema, json.RawMessage(tt.data))
+		var got []string
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			for _, v := range verr.Violations {
+				got = append(got, v.String())
+			}
+		} else if err != nil {
+			t.Errorf("validate(%s): %v", tt.data, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("validate(%s) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCompiledSchemaConcurrentUse(t *testing.T) {
+	cs, err := compileSchema(json.RawMessage(`{"$defs": {"id": {"pattern": "^[a-z]+$"}}, "items": {"$ref": "#/$defs/id"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cs.validate(json.RawMessage(`["abc", "def"]`)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}